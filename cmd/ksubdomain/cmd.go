@@ -18,6 +18,8 @@ func main() {
 			verifyCommand,
 			testCommand,
 			deviceCommand,
+			resolversCommand,
+			permuteCommand,
 		},
 		Before: func(c *cli.Context) error {
 			silent := false
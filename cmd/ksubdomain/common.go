@@ -1,7 +1,15 @@
 // cmd/ksubdomain/common.go
 package main
 
-import "github.com/urfave/cli/v2"
+import (
+    "github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+    "github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+    "github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+    "github.com/boy-hack/ksubdomain/v2/pkg/device"
+    output2 "github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter/output"
+    "github.com/boy-hack/ksubdomain/v2/pkg/utils"
+    "github.com/urfave/cli/v2"
+)
 
 var CommonFlags = []cli.Flag{
     &cli.StringSliceFlag{
@@ -17,7 +25,7 @@ var CommonFlags = []cli.Flag{
     &cli.StringFlag{
         Name:    "resolvers",
         Aliases: []string{"r"},
-        Usage:   "DNS解析器列表文件",
+        Usage:   "DNS解析器列表文件，或逗号分隔的解析器，支持 ip:port，验证模式支持 tls://host 和 https://host/dns-query",
         Value:   "",
     },
     &cli.StringFlag{
@@ -39,15 +47,20 @@ var CommonFlags = []cli.Flag{
     &cli.StringFlag{
         Name:    "output",
         Aliases: []string{"o"},
-        Usage:   "输出文件路径",
+        Usage:   "输出文件路径，jsonl 类型可使用 - 输出到标准输出",
         Value:   "",
     },
     &cli.StringFlag{
         Name:    "output-type",
         Aliases: []string{"ot"},
-        Usage:   "输出类型: txt, json, csv",
+        Usage:   "输出类型: txt, json, csv, jsonl",
         Value:   "txt",
     },
+    &cli.BoolFlag{
+        Name:    "output-summary",
+        Usage:   "jsonl 输出结束时追加一行扫描统计信息",
+        Value:   false,
+    },
     &cli.BoolFlag{
         Name:    "silent",
         Usage:   "静默模式",
@@ -61,17 +74,151 @@ var CommonFlags = []cli.Flag{
     },
     &cli.StringFlag{
         Name:    "wild-filter-mode",
-        Usage:   "泛解析过滤模式: none, local, remote",
-        Value:   "local",
+        Usage:   "泛解析过滤模式: none, basic, advanced, zone-probe, combined (basic、advanced、combined 在扫描结束后统计过滤，只能用于 txt/json/csv 文件输出，屏幕不显示结果)",
+        Value:   utils.DefaultWildcardFilterMode,
     },
     &cli.BoolFlag{
         Name:    "predict",
         Usage:   "启用预测模式",
         Value:   false,
     },
+    &cli.StringFlag{
+        Name:    "predict-cfg",
+        Usage:   "预测模式的模式配置文件，支持 {分类}、{num:1-20} 标签和 @insert/@replace/@increment 变换 (默认使用内置配置)",
+    },
+    &cli.StringFlag{
+        Name:    "predict-dict",
+        Usage:   "预测模式的分类字典文件，格式为 [分类] 后跟每行一个值 (默认使用内置字典)",
+    },
+    &cli.IntFlag{
+        Name:    "predict-per-seed",
+        Usage:   "预测模式每个解析成功的域名最多生成的候选数量",
+        Value:   5000,
+    },
+    &cli.IntFlag{
+        Name:    "predict-max",
+        Usage:   "预测模式整个扫描最多生成的候选数量",
+        Value:   1000000,
+    },
+    &cli.StringSliceFlag{
+        Name:    "qtype",
+        Usage:   "查询记录类型，可多次指定: a, aaaa, cname, ns, txt, mx, soa, srv, caa, ptr (默认 a)",
+    },
+    &cli.IntFlag{
+        Name:    "src-ports",
+        Usage:   "发送查询使用的源端口数量，查询轮流使用这些端口 (1-256)",
+        Value:   1,
+    },
+    &cli.StringFlag{
+        Name:    "transport",
+        Usage:   "发包方式: pcap 原始发包(需要 root 或 CAP_NET_RAW 权限), udp 普通socket(无需特殊权限)",
+        Value:   options.TransportPcap,
+    },
+    &cli.StringFlag{
+        Name:    "resume",
+        Usage:   "断点续扫状态文件，文件存在时从上次中断处继续",
+        Value:   "",
+    },
     &cli.StringFlag{
         Name:    "eth",
         Aliases: []string{"e"},
         Usage:   "指定网卡名称",
     },
-}
\ No newline at end of file
+}
+
+// checkWildFilterMode 校验并返回泛解析过滤模式
+func checkWildFilterMode(c *cli.Context) string {
+    mode := c.String("wild-filter-mode")
+    if _, err := utils.NewWildcardFilter(mode); err != nil {
+        gologger.Fatalf("%s\n", err.Error())
+    }
+    return mode
+}
+
+// checkLiveOutput 校验 jsonl 等实时输出可用的泛解析过滤模式，失败时退出
+func checkLiveOutput(wildFilterMode string) {
+    if err := utils.CheckLiveWildcardMode(wildFilterMode); err != nil {
+        gologger.Fatalf("%s\n", err.Error())
+    }
+}
+
+// printScreen 是否逐条打印结果到屏幕；需要在扫描结束后统计的过滤模式下结果只写入 txt/json/csv 文件，
+// 屏幕不显示结果，没有文件输出时退出
+func printScreen(c *cli.Context, wildFilterMode string) bool {
+    if c.Bool("not-print") || c.String("output") == output2.StdoutFilename {
+        return false
+    }
+    if utils.CheckLiveWildcardMode(wildFilterMode) == nil {
+        return true
+    }
+    if c.String("output") == "" || c.String("output-type") == "jsonl" {
+        checkLiveOutput(wildFilterMode)
+    }
+    gologger.Infof("泛解析过滤模式 %s 在扫描结束后统计过滤，结果只写入 %s，不在屏幕显示\n", wildFilterMode, c.String("output"))
+    return false
+}
+
+// loadPredictRules 加载预测规则，未指定配置和字典时返回 nil 使用内置规则
+func loadPredictRules(c *cli.Context) *predict.Rules {
+    if c.String("predict-cfg") == "" && c.String("predict-dict") == "" {
+        return nil
+    }
+    rules, err := predict.LoadRules(c.String("predict-cfg"), c.String("predict-dict"))
+    if err != nil {
+        gologger.Fatalf("加载预测规则失败：%s\n", err.Error())
+    }
+    return rules
+}
+
+// bandRate 将带宽参数换算为发包速率，参数错误时退出
+func bandRate(c *cli.Context) int64 {
+    rate, err := options.Band2Rate(c.String("band"))
+    if err != nil {
+        gologger.Fatalf("%s\n", err.Error())
+    }
+    return rate
+}
+
+// deviceConfig 获取网卡配置，失败时退出
+func deviceConfig(resolvers []string) *device.EtherTable {
+    ether, err := options.GetDeviceConfig(resolvers)
+    if err != nil {
+        gologger.Fatalf("%s\n", err.Error())
+    }
+    return ether
+}
+
+// sourcePorts 校验并返回源端口数量
+func sourcePorts(c *cli.Context) int {
+    n := c.Int("src-ports")
+    if n < 1 || n > 256 {
+        gologger.Fatalf("源端口数量需要在 1-256 之间: %d\n", n)
+    }
+    return n
+}
+
+// authPort 校验并返回权威服务器端口
+func authPort(c *cli.Context) uint16 {
+    port := c.Int("auth-port")
+    if port < 1 || port > 65535 {
+        gologger.Fatalf("权威服务器端口需要在 1-65535 之间: %d\n", port)
+    }
+    return uint16(port)
+}
+
+// transportMode 校验并返回发包方式
+func transportMode(c *cli.Context) string {
+    mode := c.String("transport")
+    if mode != options.TransportPcap && mode != options.TransportUDP {
+        gologger.Fatalf("发包方式只能是 pcap 或 udp: %s\n", mode)
+    }
+    return mode
+}
+
+// etherInfo 按发包方式获取网卡配置，udp 发包方式和加密解析器不需要网卡
+func etherInfo(c *cli.Context, resolvers []string) *device.EtherTable {
+    if transportMode(c) == options.TransportUDP || options.HasEncryptedResolver(resolvers) {
+        return nil
+    }
+    return deviceConfig(resolvers)
+}
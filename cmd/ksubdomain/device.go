@@ -14,22 +14,38 @@ var deviceCommand = &cli.Command{
 	Flags: []cli.Flag{},
 	Action: func(c *cli.Context) error {
 		// 否则列出所有可用的网卡
-		deviceNames, deviceMap := device.GetAllIPv4Devices()
+		deviceNames, deviceMap, err := device.GetAllIPv4Devices()
+		if err != nil {
+			gologger.Fatalf("%s\n", err.Error())
+		}
+		deviceNames6, deviceMap6, err := device.GetAllIPv6Devices()
+		if err != nil {
+			gologger.Fatalf("%s\n", err.Error())
+		}
+		for _, name := range deviceNames6 {
+			if _, ok := deviceMap[name]; !ok {
+				deviceNames = append(deviceNames, name)
+			}
+		}
 
 		if len(deviceNames) == 0 {
-			gologger.Warningf("未找到可用的IPv4网卡\n")
+			gologger.Warningf("未找到可用的网卡\n")
 			return nil
 		}
 
 		gologger.Infof("系统发现 %d 个可用的网卡:\n", len(deviceNames))
 
 		for i, name := range deviceNames {
-			ip := deviceMap[name]
 			gologger.Infof("[%d] 网卡名称: %s\n", i+1, name)
-			gologger.Infof("    IP地址: %s\n", ip.String())
+			if ip, ok := deviceMap[name]; ok {
+				gologger.Infof("    IP地址: %s\n", ip.String())
+			}
+			if ip, ok := deviceMap6[name]; ok {
+				gologger.Infof("    IPv6地址: %s\n", ip.String())
+			}
 			fmt.Println("")
 		}
-		ether, err := device.AutoGetDevices([]string{"1.1.1.1", "8.8.8.8"})
+		ether, err := device.AutoGetDevices([]string{"1.1.1.1", "8.8.8.8", "2606:4700:4700::1111"})
 		if err != nil {
 			gologger.Errorf("获取网卡信息失败: %s\n", err.Error())
 			return nil
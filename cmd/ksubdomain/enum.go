@@ -6,7 +6,10 @@ import (
     "context"
     "math/rand"  // 保留 math/rand，因为使用了 rand.Intn
     "os"
+    "os/signal"
+    "sort"
     "strings"    // 保留 strings，因为使用了 strings.Join 等函数
+    "syscall"
 
     // 删除 fmt 导入
     core2 "github.com/boy-hack/ksubdomain/v2/pkg/core"
@@ -17,6 +20,7 @@ import (
     "github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
     output2 "github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter/output"
     processbar2 "github.com/boy-hack/ksubdomain/v2/pkg/runner/processbar"
+    "github.com/boy-hack/ksubdomain/v2/pkg/utils"
     "github.com/urfave/cli/v2"
 )
 
@@ -39,6 +43,21 @@ var enumCommand = &cli.Command{
             Usage:   "读取域名的NS记录并添加到解析器中",
             Value:   false,
         },
+        &cli.BoolFlag{
+            Name:    "authoritative",
+            Usage:   "权威直连模式，发现域名及子区域委派的NS，以RD=0直接查询权威服务器",
+            Value:   false,
+        },
+        &cli.IntFlag{
+            Name:    "auth-rate",
+            Usage:   "权威直连模式下每个权威服务器每秒最多发送的查询数",
+            Value:   runner.DefaultAuthRate,
+        },
+        &cli.IntFlag{
+            Name:    "auth-port",
+            Usage:   "权威直连模式下权威服务器的端口",
+            Value:   options.DefaultDNSPort,
+        },
         &cli.StringFlag{
             Name:    "domain-list",
             Aliases: []string{"ds"},
@@ -55,6 +74,16 @@ var enumCommand = &cli.Command{
             Usage:   "禁用所有在线子域名查询，仅使用字典爆破",
             Value:   false,
         },
+        &cli.IntFlag{
+            Name:    "depth",
+            Usage:   "递归枚举深度，大于1时对解析成功的子域名继续爆破下一级，如 2 表示枚举到 a.b.example.com",
+            Value:   1,
+        },
+        &cli.StringFlag{
+            Name:    "depth-dict",
+            Usage:   "递归枚举使用的字典文件，如未指定则使用内置 subnext 字典",
+            Value:   "",
+        },
     }...),
     Action: func(c *cli.Context) error {
         gologger.Printf("\n")
@@ -123,20 +152,14 @@ var enumCommand = &cli.Command{
         gologger.Printf("\n")
 
         // ==================== 泛解析检测 ====================
-        gologger.Infof("[2/5] 正在检测泛解析...\n")
-        wildIPS := make([]string, 0)
-        if c.String("wild-filter-mode") != "none" {
-            for _, domain := range domains {
-                ok, ips := runner.IsWildCard(domain)
-                if ok {
-                    wildIPS = append(wildIPS, ips...)
-                    gologger.Warningf("检测到泛解析域名：%s (IP: %v)\n", domain, ips)
-                }
-            }
-            gologger.Infof("泛解析检测完成\n")
+        gologger.Infof("[2/5] 泛解析检测...\n")
+        wildFilterMode := checkWildFilterMode(c)
+        if wildFilter, _ := utils.NewWildcardFilter(wildFilterMode); wildFilter.ZoneProbe() {
+            // 扫描过程中对发现的每一级区域使用随机子域名探测泛解析，并按区域过滤结果
+            gologger.Infof("将在扫描过程中按区域探测泛解析\n")
         }
         gologger.Printf("\n")
-
+        
         // ==================== 在线子域名收集 ====================
         var onlineSubdomains map[string][]string
         
@@ -295,7 +318,10 @@ var enumCommand = &cli.Command{
             dictCount := 0
             
             // 第一阶段：发送从在线源获取的子域名
-            for _, subdomains := range onlineSubdomains {
+            // 按固定顺序发送，断点续扫时才能按位置跳过
+            for _, domain := range domains {
+                subdomains := onlineSubdomains[domain]
+                sort.Strings(subdomains)
                 for _, subdomain := range subdomains {
                     if !sentSubdomains[subdomain] {
                         sentSubdomains[subdomain] = true
@@ -368,7 +394,10 @@ var enumCommand = &cli.Command{
         gologger.Infof("[5/5] 正在进行扫描前准备...\n")
         
         specialDns := make(map[string][]string)
-        defaultResolver := options.GetResolvers(c.StringSlice("resolvers"))
+        defaultResolver, err := options.GetResolvers(c.String("resolvers"))
+        if err != nil {
+            gologger.Fatalf("%s\n", err.Error())
+        }
         
         if c.Bool("ns") {
             gologger.Infof("正在查询域名NS记录...\n")
@@ -387,20 +416,29 @@ var enumCommand = &cli.Command{
         // ==================== 配置输出器 ====================
         gologger.Debugf("正在配置输出...\n")
         
+        // 结果写入标准输出时屏蔽日志、进度条和屏幕输出，便于直接交给 jq 等工具处理
+        toStdout := c.String("output") == output2.StdoutFilename
+        if toStdout {
+            if c.String("output-type") != "jsonl" {
+                gologger.Fatalf("仅 jsonl 输出类型支持写入标准输出\n")
+            }
+            gologger.MaxLevel = gologger.Fatal
+            processBar.Silent = true
+        }
+        
         screenWriter, err := output2.NewScreenOutput(c.Bool("silent"))
         if err != nil {
             gologger.Fatalf("创建屏幕输出器失败：%s\n", err.Error())
         }
         
         var writers []outputter.Output
-        if !c.Bool("not-print") {
+        if printScreen(c, wildFilterMode) {
             writers = append(writers, screenWriter)
         }
         
         if c.String("output") != "" {
             outputFile := c.String("output")
             outputType := c.String("output-type")
-            wildFilterMode := c.String("wild-filter-mode")
             
             gologger.Infof("结果将输出到：%s (%s 格式)\n", outputFile, outputType)
             
@@ -412,21 +450,39 @@ var enumCommand = &cli.Command{
                 }
                 writers = append(writers, p)
             case "json":
-                p := output2.NewJsonOutput(outputFile, wildFilterMode)
+                p, err := output2.NewJsonOutput(outputFile, wildFilterMode)
+                if err != nil {
+                    gologger.Fatalf("创建JSON输出器失败：%s\n", err.Error())
+                }
                 writers = append(writers, p)
             case "csv":
-                p := output2.NewCsvOutput(outputFile, wildFilterMode)
+                p, err := output2.NewCsvOutput(outputFile, wildFilterMode)
+                if err != nil {
+                    gologger.Fatalf("创建CSV输出器失败：%s\n", err.Error())
+                }
+                writers = append(writers, p)
+            case "jsonl":
+                checkLiveOutput(wildFilterMode)
+                p, err := output2.NewJsonlOutput(outputFile, c.Bool("output-summary"))
+                if err != nil {
+                    gologger.Fatalf("创建JSONL输出器失败：%s\n", err.Error())
+                }
                 writers = append(writers, p)
             default:
-                gologger.Fatalf("不支持的输出类型：%s (支持：txt, json, csv)\n", outputType)
+                gologger.Fatalf("不支持的输出类型：%s (支持：txt, json, csv, jsonl)\n", outputType)
             }
         }
         
         // ==================== 配置扫描器 ====================
         gologger.Debugf("正在配置扫描器参数...\n")
         
+        queryTypes, err := options.ParseQueryTypes(c.StringSlice("qtype"))
+        if err != nil {
+            gologger.Fatalf("%s\n", err.Error())
+        }
+        
         opt := &options.Options{
-            Rate:               options.Band2Rate(c.String("band")),
+            Rate:               bandRate(c),
             Domain:             render,
             Resolvers:          defaultResolver,
             Silent:             c.Bool("silent"),
@@ -436,13 +492,33 @@ var enumCommand = &cli.Command{
             Writer:             writers,
             ProcessBar:         processBar,
             SpecialResolvers:   specialDns,
-            WildcardFilterMode: c.String("wild-filter-mode"),
-            WildIps:            wildIPS,
+            WildcardFilterMode: wildFilterMode,
             Predict:            c.Bool("predict"),
+            PredictRules:       loadPredictRules(c),
+            PredictPerSeed:     c.Int("predict-per-seed"),
+            PredictMax:         c.Int("predict-max"),
+            QueryTypes:         queryTypes,
+            ResumeFile:         c.String("resume"),
+            SourcePorts:        sourcePorts(c),
+            Transport:          transportMode(c),
+            RootDomains:        domains,
+            Depth:              c.Int("depth"),
+            Authoritative:      c.Bool("authoritative"),
+            AuthRate:           c.Int("auth-rate"),
+            AuthPort:           authPort(c),
+        }
+        
+        // 加载递归枚举字典
+        if opt.Depth > 1 && c.String("depth-dict") != "" {
+            words, err := core2.LinesInFile(c.String("depth-dict"))
+            if err != nil {
+                gologger.Fatalf("读取递归字典失败：%s\n", err.Error())
+            }
+            opt.DepthWords = words
         }
         
         opt.Check()
-        opt.EtherInfo = options.GetDeviceConfig(defaultResolver)
+        opt.EtherInfo = etherInfo(c, defaultResolver)
         
         // ==================== 开始扫描 ====================
         gologger.Printf("\n")
@@ -450,11 +526,19 @@ var enumCommand = &cli.Command{
         gologger.Infof("目标总数：%d\n", totalToScan)
         gologger.Infof("DNS解析器：%d 个\n", len(defaultResolver))
         gologger.Infof("扫描速率：%s\n", c.String("band"))
-        gologger.Infof("泛解析过滤：%s\n", c.String("wild-filter-mode"))
+        gologger.Infof("泛解析过滤：%s\n", wildFilterMode)
+        if opt.Depth > 1 {
+            gologger.Infof("递归深度：%d\n", opt.Depth)
+        }
         gologger.Infof("=====================================\n")
         gologger.Printf("\n")
         
-        ctx := context.Background()
+        if opt.ResumeFile != "" && !c.Bool("no-online") {
+            gologger.Warningf("断点续扫按目标顺序跳过已发送部分，在线数据源结果变化时可能导致位置偏移，建议配合 --no-online 使用\n")
+        }
+        
+        ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+        defer stop()
         r, err := runner.New(opt)
         if err != nil {
             gologger.Fatalf("创建扫描器失败：%s\n", err.Error())
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	core2 "github.com/boy-hack/ksubdomain/v2/pkg/core"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+	"github.com/urfave/cli/v2"
+)
+
+var permuteCommand = &cli.Command{
+	Name:  "permute",
+	Usage: "根据预测规则生成候选域名并输出，不进行解析",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "domain",
+			Aliases: []string{"d"},
+			Usage:   "已知域名",
+		},
+		&cli.StringFlag{
+			Name:    "filename",
+			Aliases: []string{"f"},
+			Usage:   "已知域名文件",
+		},
+		&cli.BoolFlag{
+			Name:  "stdin",
+			Usage: "从标准输入读取",
+		},
+		&cli.StringFlag{
+			Name:  "predict-cfg",
+			Usage: "模式配置文件 (默认使用内置配置)",
+		},
+		&cli.StringFlag{
+			Name:  "predict-dict",
+			Usage: "分类字典文件 (默认使用内置字典)",
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "输出文件，默认输出到屏幕",
+		},
+	},
+	Action: func(c *cli.Context) error {
+		domains := c.StringSlice("domain")
+		if c.String("filename") != "" {
+			lines, err := core2.LinesInFile(c.String("filename"))
+			if err != nil {
+				gologger.Fatalf("读取文件失败：%s\n", err.Error())
+			}
+			domains = append(domains, lines...)
+		}
+		if c.Bool("stdin") {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				domains = append(domains, scanner.Text())
+			}
+		}
+		if len(domains) == 0 {
+			return cli.ShowSubcommandHelp(c)
+		}
+
+		rules, err := predict.LoadRules(c.String("predict-cfg"), c.String("predict-dict"))
+		if err != nil {
+			gologger.Fatalf("加载预测规则失败：%s\n", err.Error())
+		}
+
+		out := os.Stdout
+		if c.String("output") != "" {
+			out, err = os.Create(c.String("output"))
+			if err != nil {
+				gologger.Fatalf("创建文件:%s 出现错误:%s\n", c.String("output"), err.Error())
+			}
+			defer out.Close()
+		}
+		w := bufio.NewWriter(out)
+
+		candidates := make(chan string, 1000)
+		done := make(chan int)
+		go func() {
+			seen := make(map[string]struct{})
+			for domain := range candidates {
+				if _, ok := seen[domain]; ok {
+					continue
+				}
+				seen[domain] = struct{}{}
+				fmt.Fprintln(w, domain)
+			}
+			done <- len(seen)
+		}()
+		for _, domain := range domains {
+			if domain == "" {
+				continue
+			}
+			if _, err := predict.PredictDomainsWithRules(domain, rules, candidates); err != nil {
+				gologger.Fatalf("生成候选域名失败：%s\n", err.Error())
+			}
+		}
+		close(candidates)
+		total := <-done
+		if err := w.Flush(); err != nil {
+			gologger.Fatalf("写入结果失败: %s\n", err.Error())
+		}
+		gologger.Infof("生成候选域名 %d 个\n", total)
+		return nil
+	},
+}
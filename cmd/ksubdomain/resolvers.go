@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner"
+	"github.com/urfave/cli/v2"
+)
+
+var resolversCommand = &cli.Command{
+	Name:  "resolvers",
+	Usage: "DNS解析器列表管理",
+	Subcommands: []*cli.Command{
+		resolversCheckCommand,
+	},
+}
+
+var resolversCheckCommand = &cli.Command{
+	Name:  "check",
+	Usage: "检测解析器列表，剔除无响应、劫持或投毒的解析器",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:     "file",
+			Aliases:  []string{"f"},
+			Usage:    "待检测的解析器列表文件，或逗号分隔的解析器",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "可用解析器输出文件，默认输出到屏幕",
+		},
+		&cli.StringFlag{
+			Name:    "band",
+			Aliases: []string{"b"},
+			Usage:   "带宽控制，如 2M,500k",
+			Value:   "2M",
+		},
+		&cli.IntFlag{
+			Name:  "retry",
+			Usage: "重试次数",
+			Value: 2,
+		},
+		&cli.IntFlag{
+			Name:  "timeout",
+			Usage: "每轮等待响应的时间(秒)",
+			Value: 3,
+		},
+		&cli.IntFlag{
+			Name:  "nx-probes",
+			Usage: "每个解析器查询的随机不存在域名数量",
+			Value: 2,
+		},
+		&cli.StringFlag{
+			Name:  "transport",
+			Usage: "发包方式: pcap 原始发包(需要 root 或 CAP_NET_RAW 权限), udp 普通socket(无需特殊权限)",
+			Value: options.TransportPcap,
+		},
+	},
+	Action: func(c *cli.Context) error {
+		resolvers, err := options.GetResolvers(c.String("file"))
+		if err != nil {
+			gologger.Fatalf("%s\n", err.Error())
+		}
+		gologger.Infof("待检测解析器: %d 个\n", len(resolvers))
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		results, err := runner.CheckResolvers(ctx, runner.ResolverCheckOptions{
+			Resolvers: resolvers,
+			EtherInfo: etherInfo(c, options.DefaultResolvers()),
+			Transport: transportMode(c),
+			Rate:      bandRate(c),
+			Timeout:   time.Duration(c.Int("timeout")) * time.Second,
+			Retry:     c.Int("retry"),
+			NXProbes:  c.Int("nx-probes"),
+		})
+		if err != nil {
+			gologger.Fatalf("检测解析器失败: %s\n", err.Error())
+		}
+
+		out := os.Stdout
+		if c.String("output") != "" {
+			out, err = os.Create(c.String("output"))
+			if err != nil {
+				gologger.Fatalf("创建文件:%s 出现错误:%s\n", c.String("output"), err.Error())
+			}
+			defer out.Close()
+		}
+		w := bufio.NewWriter(out)
+		var valid int
+		for _, ret := range results {
+			if !ret.Valid {
+				gologger.Warningf("剔除 %s: %s\n", ret.Resolver, ret.Reason)
+				continue
+			}
+			valid++
+			fmt.Fprintln(w, ret.Resolver)
+		}
+		if err := w.Flush(); err != nil {
+			gologger.Fatalf("写入结果失败: %s\n", err.Error())
+		}
+		gologger.Infof("检测完成，可用解析器 %d/%d 个\n", valid, len(results))
+		return nil
+	},
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner"
 	"github.com/urfave/cli/v2"
@@ -17,8 +18,10 @@ var testCommand = &cli.Command{
 		},
 	},
 	Action: func(c *cli.Context) error {
-		ethTable := options.GetDeviceConfig(nil)
-		runner.TestSpeed(ethTable)
+		ethTable := deviceConfig(nil)
+		if err := runner.TestSpeed(ethTable); err != nil {
+			gologger.Fatalf("%s\n", err.Error())
+		}
 		return nil
 	},
 }
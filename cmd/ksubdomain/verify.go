@@ -4,6 +4,8 @@ import (
     "bufio"
     "context"
     "os"
+    "os/signal"
+    "syscall"
 
     "github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
     "github.com/boy-hack/ksubdomain/v2/pkg/core/options"
@@ -31,6 +33,7 @@ var verifyCommand = &cli.Command{
         if c.NumFlags() == 0 {
             cli.ShowCommandHelpAndExit(c, "verify", 0)
         }
+        wildFilterMode := checkWildFilterMode(c)
         
         var domains []string
         processBar := &processbar2.ScreenProcess{Silent: c.Bool("silent")}
@@ -81,13 +84,25 @@ var verifyCommand = &cli.Command{
             processBar = nil
         }
         
+        // 结果写入标准输出时屏蔽日志、进度条和屏幕输出，便于直接交给 jq 等工具处理
+        toStdout := c.String("output") == output2.StdoutFilename
+        if toStdout {
+            if c.String("output-type") != "jsonl" {
+                gologger.Fatalf("仅 jsonl 输出类型支持写入标准输出\n")
+            }
+            gologger.MaxLevel = gologger.Fatal
+            if processBar != nil {
+                processBar.Silent = true
+            }
+        }
+        
         screenWriter, err := output2.NewScreenOutput(c.Bool("silent"))
         if err != nil {
             gologger.Fatalf(err.Error() + "\n")
         }
         
         var writer []outputter.Output
-        if !c.Bool("not-print") {
+        if printScreen(c, wildFilterMode) {
             writer = append(writer, screenWriter)
         }
         
@@ -95,7 +110,6 @@ var verifyCommand = &cli.Command{
         if c.String("output") != "" {
             outputFile := c.String("output")
             outputType := c.String("output-type")
-            wildFilterMode := c.String("wild-filter-mode")
             
             switch outputType {
             case "txt":
@@ -105,10 +119,23 @@ var verifyCommand = &cli.Command{
                 }
                 writer = append(writer, p)
             case "json":
-                p := output2.NewJsonOutput(outputFile, wildFilterMode)
+                p, err := output2.NewJsonOutput(outputFile, wildFilterMode)
+                if err != nil {
+                    gologger.Fatalf(err.Error() + "\n")
+                }
                 writer = append(writer, p)
             case "csv":
-                p := output2.NewCsvOutput(outputFile, wildFilterMode)
+                p, err := output2.NewCsvOutput(outputFile, wildFilterMode)
+                if err != nil {
+                    gologger.Fatalf(err.Error() + "\n")
+                }
+                writer = append(writer, p)
+            case "jsonl":
+                checkLiveOutput(wildFilterMode)
+                p, err := output2.NewJsonlOutput(outputFile, c.Bool("output-summary"))
+                if err != nil {
+                    gologger.Fatalf(err.Error() + "\n")
+                }
                 writer = append(writer, p)
             default:
                 gologger.Fatalf("输出类型错误:%s 暂不支持\n", outputType)
@@ -116,9 +143,16 @@ var verifyCommand = &cli.Command{
         }
         
         // 配置扫描器
-        resolver := options.GetResolvers(c.StringSlice("resolvers"))
+        resolver, err := options.GetResolvers(c.String("resolvers"))
+        if err != nil {
+            gologger.Fatalf("%s\n", err.Error())
+        }
+        queryTypes, err := options.ParseQueryTypes(c.StringSlice("qtype"))
+        if err != nil {
+            gologger.Fatalf("%s\n", err.Error())
+        }
         opt := &options.Options{
-            Rate:               options.Band2Rate(c.String("band")),
+            Rate:               bandRate(c),
             Domain:             render,
             Resolvers:          resolver,
             Silent:             c.Bool("silent"),
@@ -127,15 +161,23 @@ var verifyCommand = &cli.Command{
             Method:             options.VerifyType,
             Writer:             writer,
             ProcessBar:         processBar,
-            EtherInfo:          options.GetDeviceConfig(resolver),
-            WildcardFilterMode: c.String("wild-filter-mode"),
+            EtherInfo:          etherInfo(c, resolver),
+            WildcardFilterMode: wildFilterMode,
             Predict:            c.Bool("predict"),
+            PredictRules:       loadPredictRules(c),
+            PredictPerSeed:     c.Int("predict-per-seed"),
+            PredictMax:         c.Int("predict-max"),
+            QueryTypes:         queryTypes,
+            ResumeFile:         c.String("resume"),
+            SourcePorts:        sourcePorts(c),
+            Transport:          transportMode(c),
         }
         
         opt.Check()
         
         // 运行验证
-        ctx := context.Background()
+        ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+        defer stop()
         r, err := runner.New(opt)
         if err != nil {
             gologger.Fatalf("%s\n", err.Error())
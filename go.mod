@@ -13,6 +13,7 @@ require (
 	github.com/urfave/cli/v2 v2.3.0
 	go.uber.org/ratelimit v0.2.0
 	golang.org/x/crypto v0.37.0
+	golang.org/x/net v0.39.0
 	golang.org/x/sys v0.32.0
 	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -27,7 +28,6 @@ require (
 	github.com/russross/blackfriday/v2 v2.0.1 // indirect
 	github.com/shurcooL/sanitized_anchor_name v1.0.0 // indirect
 	golang.org/x/mod v0.24.0 // indirect
-	golang.org/x/net v0.39.0 // indirect
 	golang.org/x/sync v0.13.0 // indirect
 	golang.org/x/term v0.31.0 // indirect
 	golang.org/x/tools v0.32.0 // indirect
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/logrusorgru/aurora"
 	"github.com/mattn/go-colorable"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -43,10 +44,18 @@ var (
 		Info:    "INFO",
 	}
 
-	mutex  = &sync.Mutex{}
-	output = colorable.NewColorableStdout()
+	mutex            = &sync.Mutex{}
+	output io.Writer = colorable.NewColorableStdout()
 )
 
+// SetOutput sets the writer that log messages are written to.
+// Silent messages are still written to stdout.
+func SetOutput(w io.Writer) {
+	mutex.Lock()
+	output = w
+	mutex.Unlock()
+}
+
 var stringBuilderPool = &sync.Pool{New: func() interface{} {
 	return new(strings.Builder)
 }}
@@ -4,24 +4,15 @@ import (
 	"errors"
 	"github.com/miekg/dns"
 	"net"
+	"strings"
 )
 
 // LookupNS returns the names servers for a domain.
 func LookupNS(domain, serverAddr string) (servers []string, ips []string, err error) {
-	m := &dns.Msg{}
-	m.SetQuestion(dns.Fqdn(domain), dns.TypeNS)
-	in, err := dns.Exchange(m, serverAddr+":53")
+	servers, err = NameServers(domain, serverAddr)
 	if err != nil {
 		return nil, nil, err
 	}
-	if len(in.Answer) == 0 {
-		return nil, nil, errors.New("no Answer")
-	}
-	for _, a := range in.Answer {
-		if ns, ok := a.(*dns.NS); ok {
-			servers = append(servers, ns.Ns)
-		}
-	}
 	for _, s := range servers {
 		ipResults, err := net.LookupIP(s)
 		if err != nil {
@@ -35,3 +26,59 @@ func LookupNS(domain, serverAddr string) (servers []string, ips []string, err er
 	}
 	return
 }
+
+// NameServers 通过 serverAddr 查询区域的NS记录，返回NS主机名
+func NameServers(domain, serverAddr string) ([]string, error) {
+	in, err := exchange(domain, dns.TypeNS, serverAddr)
+	if err != nil {
+		return nil, err
+	}
+	var servers []string
+	for _, a := range in.Answer {
+		if ns, ok := a.(*dns.NS); ok {
+			servers = append(servers, strings.TrimSuffix(ns.Ns, "."))
+		}
+	}
+	if len(servers) == 0 {
+		return nil, errors.New("no Answer")
+	}
+	return servers, nil
+}
+
+// LookupHost 通过 serverAddr 解析主机名的IPv4和IPv6地址
+func LookupHost(host, serverAddr string) ([]net.IP, error) {
+	var ips []net.IP
+	var lastErr error
+	for _, qtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		in, err := exchange(host, qtype, serverAddr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		for _, a := range in.Answer {
+			switch rr := a.(type) {
+			case *dns.A:
+				ips = append(ips, rr.A)
+			case *dns.AAAA:
+				ips = append(ips, rr.AAAA)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, errors.New("no Answer")
+	}
+	return ips, nil
+}
+
+// exchange 向 serverAddr 发送一个递归查询，未指定端口时使用53端口
+func exchange(name string, qtype uint16, serverAddr string) (*dns.Msg, error) {
+	m := &dns.Msg{}
+	m.SetQuestion(dns.Fqdn(name), qtype)
+	if _, _, err := net.SplitHostPort(serverAddr); err != nil {
+		serverAddr = net.JoinHostPort(serverAddr, "53")
+	}
+	return dns.Exchange(m, serverAddr)
+}
@@ -1,14 +1,15 @@
 package options
 
 import (
+	"fmt"
 	core2 "github.com/boy-hack/ksubdomain/v2/pkg/core"
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/device"
 	"os"
 )
 
-// GetDeviceConfig 获取网卡配置信息
-func GetDeviceConfig(dnsServer []string) *device.EtherTable {
+// GetDeviceConfig 获取网卡配置信息，优先读取配置文件，否则自动识别并保存
+func GetDeviceConfig(dnsServer []string) (*device.EtherTable, error) {
 	// 读取配置文件路径环境变量
 	var filename string
 	filename, ok := os.LookupEnv("ksubdomain-config")
@@ -23,18 +24,18 @@ func GetDeviceConfig(dnsServer []string) *device.EtherTable {
 		if err == nil {
 			gologger.Infof("读取配置 %s 成功!\n", filename)
 			device.PrintDeviceInfo(ether)
-			return ether
+			return ether, nil
 		}
 	}
 	// 自动发现外网网卡
 	gologger.Infof("正在自动识别外网网卡...\n")
 	ether, err = device.AutoGetDevices(dnsServer)
 	if err != nil {
-		gologger.Fatalf("自动识别外网网卡失败: %v\n", err)
+		return nil, fmt.Errorf("自动识别外网网卡失败: %w", err)
 	}
 	saveConfig(ether, filename)
 	device.PrintDeviceInfo(ether)
-	return ether
+	return ether, nil
 }
 
 // 保存配置到文件
@@ -1,7 +1,11 @@
 package options
 
 import (
+	"errors"
+	"fmt"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
 	device2 "github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/google/gopacket/layers"
 	"strconv"
 
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
@@ -17,6 +21,14 @@ const (
 	TestType   OptionMethod = "test"
 )
 
+// 发包方式
+const (
+	TransportPcap = "pcap" // 原始发包，需要 root 或 CAP_NET_RAW 权限
+	TransportUDP  = "udp"  // 普通UDP socket，不需要特殊权限
+
+	TransportEncrypted = "encrypted" // DoT/DoH，解析器为 tls:// 或 https:// 时自动选择
+)
+
 type Options struct {
 	Rate               int64              // 每秒发包速率
 	Domain             chan string        // 域名输入
@@ -29,14 +41,37 @@ type Options struct {
 	ProcessBar         processbar.ProcessBar
 	EtherInfo          *device2.EtherTable // 网卡信息
 	SpecialResolvers   map[string][]string // 可针对特定域名使用的dns resolvers
-	WildcardFilterMode string              // 泛解析过滤模式: "basic", "advanced", "none"
+	WildcardFilterMode string              // 泛解析过滤模式: none, basic, advanced, zone-probe, combined
 	WildIps            []string
-	Predict            bool // 是否开启预测模式
+	Predict            bool             // 是否开启预测模式
+	PredictRules       *predict.Rules   // 预测规则，为空时使用内置规则
+	PredictPerSeed     int              // 每个解析成功的域名最多预测的候选数量，小于等于0时使用默认值
+	PredictMax         int              // 整个扫描最多预测的候选数量，小于等于0时使用默认值
+	QueryTypes         []layers.DNSType // 查询的记录类型，为空时只查询A记录
+	ResumeFile         string           // 断点续扫状态文件，为空时不记录断点
+	RootDomains        []string         // 枚举的根域名，用于计算递归层级
+	Depth              int              // 递归枚举深度，大于1时对解析成功的域名继续爆破下一级
+	DepthWords         []string         // 递归枚举使用的字典，为空时使用内置 subnext 字典
+	SourcePorts        int              // 发送查询使用的源端口数量，查询轮流使用，小于1时为1
+	Transport          string           // 发包方式: pcap(默认) 或 udp，udp 与加密传输不需要网卡信息
+	Authoritative      bool             // 权威直连模式: 发现根域名及子区域的NS，以RD=0直接查询权威服务器
+	AuthRate           int              // 权威直连模式下每个权威服务器每秒最多发送的查询数，小于等于0时使用默认值
+	AuthPort           uint16           // 权威直连模式下权威服务器的端口，为0时使用53端口
 }
 
-func Band2Rate(bandWith string) int64 {
+// ErrBandwidth 带宽参数错误，可使用 errors.Is 判断
+var ErrBandwidth = errors.New("带宽格式错误")
+
+// Band2Rate 将带宽(如 5M、500k)换算为每秒发包数
+func Band2Rate(bandWith string) (int64, error) {
+	if bandWith == "" {
+		return 0, fmt.Errorf("%w: 带宽不能为空", ErrBandwidth)
+	}
 	suffix := string(bandWith[len(bandWith)-1])
-	rate, _ := strconv.ParseInt(string(bandWith[0:len(bandWith)-1]), 10, 64)
+	rate, err := strconv.ParseInt(string(bandWith[0:len(bandWith)-1]), 10, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("%w: '%s' 需要为正整数加单位，如 2M、500k", ErrBandwidth, bandWith)
+	}
 	switch suffix {
 	case "G":
 		fallthrough
@@ -51,32 +86,12 @@ func Band2Rate(bandWith string) int64 {
 	case "k":
 		rate *= 1000
 	default:
-		gologger.Fatalf("unknown bandwith suffix '%s' (supported suffixes are G,M and K)\n", suffix)
+		return 0, fmt.Errorf("%w: unknown bandwith suffix '%s' (supported suffixes are G,M and K)", ErrBandwidth, suffix)
 	}
 	packSize := int64(80) // 一个DNS包大概有74byte
 	rate = rate / packSize
-	return rate
+	return rate, nil
 }
-func GetResolvers(resolvers []string) []string {
-	// handle resolver
-	var rs []string
-	if resolvers != nil {
-		for _, resolver := range resolvers {
-			rs = append(rs, resolver)
-		}
-	} else {
-		defaultDns := []string{
-			"1.1.1.1",
-			"8.8.8.8",
-			"180.76.76.76", //百度公共 DNS
-			"180.184.1.1",  //火山引擎
-			"180.184.2.2",
-		}
-		rs = defaultDns
-	}
-	return rs
-}
-
 func (opt *Options) Check() {
 	if opt.Silent {
 		gologger.MaxLevel = gologger.Silent
@@ -0,0 +1,23 @@
+package options
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBand2Rate(t *testing.T) {
+	rate, err := Band2Rate("2M")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(25000), rate)
+
+	rate, err = Band2Rate("800k")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10000), rate)
+
+	for _, band := range []string{"", "5X", "M", "-1m", "1.5m"} {
+		_, err := Band2Rate(band)
+		assert.True(t, errors.Is(err, ErrBandwidth), band)
+	}
+}
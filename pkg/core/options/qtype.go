@@ -0,0 +1,61 @@
+package options
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/gopacket/layers"
+)
+
+// DNSTypeCAA gopacket未定义CAA记录类型(RFC 8659)
+const DNSTypeCAA layers.DNSType = 257
+
+// queryTypeNames 命令行可用的查询类型
+var queryTypeNames = map[string]layers.DNSType{
+	"a":     layers.DNSTypeA,
+	"aaaa":  layers.DNSTypeAAAA,
+	"ns":    layers.DNSTypeNS,
+	"cname": layers.DNSTypeCNAME,
+	"ptr":   layers.DNSTypePTR,
+	"txt":   layers.DNSTypeTXT,
+	"mx":    layers.DNSTypeMX,
+	"soa":   layers.DNSTypeSOA,
+	"srv":   layers.DNSTypeSRV,
+	"caa":   DNSTypeCAA,
+}
+
+// QueryTypeName 返回查询类型的名称，补充gopacket未识别的类型
+func QueryTypeName(t layers.DNSType) string {
+	if t == DNSTypeCAA {
+		return "CAA"
+	}
+	return t.String()
+}
+
+// ParseQueryTypes 将类型名称转换为DNS查询类型，未指定时默认查询A记录
+func ParseQueryTypes(names []string) ([]layers.DNSType, error) {
+	var types []layers.DNSType
+	seen := make(map[layers.DNSType]bool)
+	for _, name := range names {
+		// 兼容 --qtype a,aaaa 的写法
+		for _, item := range strings.Split(name, ",") {
+			item = strings.ToLower(strings.TrimSpace(item))
+			if item == "" {
+				continue
+			}
+			t, ok := queryTypeNames[item]
+			if !ok {
+				return nil, fmt.Errorf("不支持的查询类型: %s", item)
+			}
+			if seen[t] {
+				continue
+			}
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		types = append(types, layers.DNSTypeA)
+	}
+	return types, nil
+}
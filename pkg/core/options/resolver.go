@@ -0,0 +1,179 @@
+package options
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core"
+)
+
+// DefaultDNSPort 解析器默认端口
+const DefaultDNSPort = 53
+
+// DefaultDoTPort DNS-over-TLS 解析器默认端口
+const DefaultDoTPort = 853
+
+// 加密解析器的前缀
+const (
+	SchemeDoT = "tls://"
+	SchemeDoH = "https://"
+)
+
+// DefaultResolvers 未指定解析器时使用的默认解析器
+func DefaultResolvers() []string {
+	return []string{
+		"1.1.1.1",
+		"8.8.8.8",
+		"180.76.76.76", //百度公共 DNS
+		"180.184.1.1",  //火山引擎
+		"180.184.2.2",
+	}
+}
+
+// GetResolvers 解析 --resolvers 参数，支持解析器列表文件或逗号分隔的列表，
+// 每项为 ip 或 ip:port（IPv6 写作 [ip]:port），或 tls://host[:port]、https://host/dns-query 形式的加密解析器，
+// 以 # 开头的内容为注释。
+// 参数为空时返回默认解析器
+func GetResolvers(input string) ([]string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return DefaultResolvers(), nil
+	}
+	var lines []string
+	if core.FileExists(input) {
+		var err error
+		lines, err = core.LinesInFile(input)
+		if err != nil {
+			return nil, fmt.Errorf("读取解析器文件 %s 失败: %w", input, err)
+		}
+	} else {
+		lines = []string{input}
+	}
+
+	var rs []string
+	seen := make(map[string]struct{})
+	for n, line := range lines {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		for _, entry := range strings.Split(line, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			resolver, err := ParseResolver(entry)
+			if err != nil {
+				if len(lines) > 1 {
+					return nil, fmt.Errorf("%s 第%d行: %w", input, n+1, err)
+				}
+				return nil, err
+			}
+			if _, ok := seen[resolver]; ok {
+				continue
+			}
+			seen[resolver] = struct{}{}
+			rs = append(rs, resolver)
+		}
+	}
+	if len(rs) == 0 {
+		return nil, fmt.Errorf("解析器列表为空: %s", input)
+	}
+	return rs, nil
+}
+
+// ParseResolver 校验单个解析器并转换为规范形式：53端口只保留IP，其余端口为 ip:port；
+// 加密解析器见 parseEncryptedResolver
+func ParseResolver(entry string) (string, error) {
+	if IsEncryptedResolver(entry) {
+		return parseEncryptedResolver(entry)
+	}
+	host, port := entry, DefaultDNSPort
+	if h, p, err := net.SplitHostPort(entry); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 65535 {
+			return "", fmt.Errorf("无效的解析器端口: %s", entry)
+		}
+		host, port = h, n
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", fmt.Errorf("无效的解析器地址: %s", entry)
+	}
+	return JoinResolver(ip, uint16(port)), nil
+}
+
+// JoinResolver 由IP和端口生成解析器的规范形式
+func JoinResolver(ip net.IP, port uint16) string {
+	if port == DefaultDNSPort {
+		return ip.String()
+	}
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+}
+
+// SplitResolver 拆分规范形式的解析器为IP和端口
+func SplitResolver(resolver string) (net.IP, uint16) {
+	if host, p, err := net.SplitHostPort(resolver); err == nil {
+		port, _ := strconv.Atoi(p)
+		return net.ParseIP(host), uint16(port)
+	}
+	return net.ParseIP(resolver), DefaultDNSPort
+}
+
+// IsEncryptedResolver 解析器是否为 DNS-over-TLS(tls://) 或 DNS-over-HTTPS(https://)
+func IsEncryptedResolver(resolver string) bool {
+	return strings.HasPrefix(resolver, SchemeDoT) || strings.HasPrefix(resolver, SchemeDoH)
+}
+
+// HasEncryptedResolver 列表中是否有加密解析器，有加密解析器时扫描不需要网卡
+func HasEncryptedResolver(resolvers []string) bool {
+	for _, resolver := range resolvers {
+		if IsEncryptedResolver(resolver) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEncryptedResolver 校验加密解析器：DoT 为 tls://host[:port]，853端口只保留主机；DoH 为完整的 https URL
+func parseEncryptedResolver(entry string) (string, error) {
+	if strings.HasPrefix(entry, SchemeDoH) {
+		u, err := url.Parse(entry)
+		if err != nil || u.Host == "" {
+			return "", fmt.Errorf("无效的DoH解析器: %s", entry)
+		}
+		if u.Path == "" {
+			u.Path = "/dns-query"
+		}
+		return u.String(), nil
+	}
+	host, port := SplitTLSResolver(entry)
+	if host == "" || port == 0 {
+		return "", fmt.Errorf("无效的DoT解析器: %s", entry)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
+	if port == DefaultDoTPort {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return SchemeDoT + host, nil
+	}
+	return SchemeDoT + net.JoinHostPort(host, strconv.Itoa(int(port))), nil
+}
+
+// SplitTLSResolver 拆分 tls://host[:port] 为主机和端口，端口无效时返回0
+func SplitTLSResolver(resolver string) (string, uint16) {
+	addr := strings.TrimPrefix(resolver, SchemeDoT)
+	if host, p, err := net.SplitHostPort(addr); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 65535 {
+			return host, 0
+		}
+		return host, uint16(n)
+	}
+	return strings.Trim(addr, "[]"), DefaultDoTPort
+}
@@ -0,0 +1,58 @@
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetResolvers(t *testing.T) {
+	rs, err := GetResolvers("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultResolvers(), rs)
+
+	rs, err = GetResolvers("1.1.1.1, 8.8.8.8:53,127.0.0.1:5353,1.1.1.1,[::1]:5300")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8", "127.0.0.1:5353", "[::1]:5300"}, rs)
+
+	filename := filepath.Join(t.TempDir(), "resolvers.txt")
+	content := "# 公共解析器\n1.1.1.1\n\n8.8.8.8 # google\n127.0.0.1:5353\n1.1.1.1\n"
+	assert.NoError(t, os.WriteFile(filename, []byte(content), 0644))
+	rs, err = GetResolvers(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8", "127.0.0.1:5353"}, rs)
+
+	_, err = GetResolvers("1.1.1.1,not-an-ip")
+	assert.Error(t, err)
+	_, err = GetResolvers("1.1.1.1:70000")
+	assert.Error(t, err)
+}
+
+func TestSplitResolver(t *testing.T) {
+	ip, port := SplitResolver("127.0.0.1:5353")
+	assert.Equal(t, "127.0.0.1", ip.String())
+	assert.Equal(t, uint16(5353), port)
+
+	ip, port = SplitResolver("8.8.8.8")
+	assert.Equal(t, "8.8.8.8", ip.String())
+	assert.Equal(t, uint16(DefaultDNSPort), port)
+}
+
+func TestParseEncryptedResolver(t *testing.T) {
+	rs, err := GetResolvers("tls://1.1.1.1:853,tls://dns.google:8853,tls://[2606:4700:4700::1111],https://dns.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tls://1.1.1.1", "tls://dns.google:8853", "tls://[2606:4700:4700::1111]", "https://dns.example.com/dns-query"}, rs)
+	assert.True(t, IsEncryptedResolver(rs[0]))
+	assert.False(t, IsEncryptedResolver("1.1.1.1"))
+
+	host, port := SplitTLSResolver("tls://[2606:4700:4700::1111]")
+	assert.Equal(t, "2606:4700:4700::1111", host)
+	assert.Equal(t, uint16(DefaultDoTPort), port)
+
+	_, err = ParseResolver("tls://1.1.1.1:0")
+	assert.Error(t, err)
+	_, err = ParseResolver("https:///dns-query")
+	assert.Error(t, err)
+}
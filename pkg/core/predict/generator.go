@@ -1,7 +1,6 @@
 package predict
 
 import (
-	"bufio"
 	_ "embed"
 	"fmt"
 	"strings"
@@ -16,76 +15,41 @@ var dict string
 
 // DomainGenerator 用于生成预测域名
 type DomainGenerator struct {
-	categories map[string][]string // 存储块分类和对应的值
-	patterns   []string            // 域名组合模式
-	subdomain  string              // 子域名部分
-	domain     string              // 根域名部分
-	output     chan string         // 输出接口
-	count      int                 // 生成的域名计数
-	mu         sync.Mutex          // 保护count和output的互斥锁
+	rules     *Rules            // 预测规则
+	name      string            // 完整的基础域名
+	subdomain string            // 子域名部分
+	domain    string            // 根域名部分
+	yield     func(string) bool // 输出接口，返回false时停止生成
+	stopped   bool              // 是否已停止生成
+	count     int               // 生成的域名计数
+	mu        sync.Mutex        // 保护count和output的互斥锁
 }
 
-// NewDomainGenerator 创建一个新的域名生成器
+// NewDomainGenerator 使用内置规则创建一个新的域名生成器
 func NewDomainGenerator(output chan string) (*DomainGenerator, error) {
-	// 创建生成器实例
-	dg := &DomainGenerator{
-		categories: make(map[string][]string),
-		output:     output,
-	}
-
-	// 加载分类字典
-	if err := dg.loadDictionary(); err != nil {
-		return nil, fmt.Errorf("加载字典文件失败: %v", err)
-	}
-
-	// 加载配置模式
-	if err := dg.loadPatterns(); err != nil {
-		return nil, fmt.Errorf("加载配置文件失败: %v", err)
-	}
-
-	return dg, nil
-}
-
-// 从字典文件加载分类信息
-func (dg *DomainGenerator) loadDictionary() error {
-	scanner := bufio.NewScanner(strings.NewReader(dict))
-	var currentCategory string
-
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-
-		// 检查是否是分类标识 [category]
-		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
-			currentCategory = line[1 : len(line)-1]
-			dg.categories[currentCategory] = []string{}
-		} else if currentCategory != "" {
-			// 如果有当前分类，添加值
-			dg.categories[currentCategory] = append(dg.categories[currentCategory], line)
-		}
+	rules, err := DefaultRules()
+	if err != nil {
+		return nil, err
 	}
-
-	return scanner.Err()
+	return NewDomainGeneratorWithRules(output, rules), nil
 }
 
-// 从配置文件加载域名生成模式
-func (dg *DomainGenerator) loadPatterns() error {
-	scanner := bufio.NewScanner(strings.NewReader(cfg))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			dg.patterns = append(dg.patterns, line)
+// NewDomainGeneratorWithRules 使用指定规则创建域名生成器
+func NewDomainGeneratorWithRules(output chan string, rules *Rules) *DomainGenerator {
+	dg := &DomainGenerator{rules: rules}
+	if output != nil {
+		dg.yield = func(domain string) bool {
+			output <- domain
+			return true
 		}
 	}
-
-	return scanner.Err()
+	return dg
 }
 
 // SetBaseDomain 设置基础域名
 func (dg *DomainGenerator) SetBaseDomain(domain string) {
 	// 分离子域名和根域名
+	dg.name = domain
 	parts := strings.Split(domain, ".")
 	if len(parts) <= 2 {
 		// 如果只有根域名 (example.com)
@@ -102,6 +66,7 @@ func (dg *DomainGenerator) SetBaseDomain(domain string) {
 func (dg *DomainGenerator) GenerateDomains() int {
 	dg.mu.Lock()
 	dg.count = 0
+	dg.stopped = false
 	dg.mu.Unlock()
 
 	// 如果没有设置子域名，则直接返回
@@ -110,7 +75,7 @@ func (dg *DomainGenerator) GenerateDomains() int {
 	}
 
 	// 遍历所有模式
-	for _, pattern := range dg.patterns {
+	for _, pattern := range dg.rules.Patterns {
 		// 递归处理每个模式中的标签替换
 		dg.processPattern(pattern, map[string]string{
 			"subdomain": dg.subdomain,
@@ -118,6 +83,11 @@ func (dg *DomainGenerator) GenerateDomains() int {
 		})
 	}
 
+	// 对已有标签执行内置变换
+	for _, t := range dg.rules.Transforms {
+		dg.transform(t)
+	}
+
 	dg.mu.Lock()
 	result := dg.count
 	dg.mu.Unlock()
@@ -126,16 +96,14 @@ func (dg *DomainGenerator) GenerateDomains() int {
 
 // processPattern 递归处理模式中的标签替换
 func (dg *DomainGenerator) processPattern(pattern string, replacements map[string]string) {
+	if dg.stopped {
+		return
+	}
 	// 查找第一个标签
 	startIdx := strings.Index(pattern, "{")
 	if startIdx == -1 {
 		// 没有更多标签，输出最终结果
-		if pattern != "" && dg.output != nil {
-			dg.mu.Lock()
-			dg.output <- pattern
-			dg.count++
-			dg.mu.Unlock()
-		}
+		dg.emit(pattern)
 		return
 	}
 
@@ -156,8 +124,13 @@ func (dg *DomainGenerator) processPattern(pattern string, replacements map[strin
 		return
 	}
 
-	// 从分类中获取替换值
-	values, exists := dg.categories[tagName]
+	// 从分类或数字范围中获取替换值
+	values, exists := dg.rules.Categories[tagName]
+	if strings.HasPrefix(tagName, numTagPrefix) {
+		if nr, err := parseNumRange(tagName); err == nil {
+			values, exists = nr.values(), true
+		}
+	}
 	if !exists || len(values) == 0 {
 		// 没有找到替换值，跳过此标签
 		newPattern := pattern[:startIdx] + pattern[endIdx+1:]
@@ -180,18 +153,52 @@ func (dg *DomainGenerator) processPattern(pattern string, replacements map[strin
 	}
 }
 
+// emit 输出一个生成的域名
+func (dg *DomainGenerator) emit(domain string) {
+	if domain == "" || dg.yield == nil {
+		return
+	}
+	dg.mu.Lock()
+	defer dg.mu.Unlock()
+	if dg.stopped {
+		return
+	}
+	if !dg.yield(domain) {
+		dg.stopped = true
+		return
+	}
+	dg.count++
+}
+
+// Generate 按规则生成 domain 的候选域名并逐个交给 fn，fn 返回 false 时停止生成。
+// Rules 只读，可在多个协程中共享同一份规则并发生成
+func (r *Rules) Generate(domain string, fn func(string) bool) int {
+	dg := &DomainGenerator{rules: r, yield: fn}
+	dg.SetBaseDomain(domain)
+	return dg.GenerateDomains()
+}
+
 // PredictDomains 根据给定域名预测可能的域名变体，直接输出结果
 func PredictDomains(domain string, output chan string) (int, error) {
+	rules, err := DefaultRules()
+	if err != nil {
+		return 0, err
+	}
+	return PredictDomainsWithRules(domain, rules, output)
+}
+
+// PredictDomainsWithRules 使用指定规则预测域名变体
+func PredictDomainsWithRules(domain string, rules *Rules, output chan string) (int, error) {
 	// 检查输出对象是否为nil
 	if output == nil {
 		return 0, fmt.Errorf("输出对象不能为空")
 	}
+	if rules == nil {
+		return 0, fmt.Errorf("预测规则不能为空")
+	}
 
 	// 创建域名生成器
-	generator, err := NewDomainGenerator(output)
-	if err != nil {
-		return 0, err
-	}
+	generator := NewDomainGeneratorWithRules(output, rules)
 
 	// 设置基础域名
 	generator.SetBaseDomain(domain)
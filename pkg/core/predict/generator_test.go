@@ -1,25 +1,88 @@
 package predict
 
 import (
-	"fmt"
 	"github.com/stretchr/testify/assert"
 	"testing"
 )
 
-type output struct {
-}
-
-func (o *output) Write(p []byte) (n int, err error) {
-	fmt.Println(string(p))
-	return len(p), nil
+// collect 运行预测并收集全部生成的域名
+func collect(t *testing.T, domain string, rules *Rules) []string {
+	output := make(chan string)
+	var domains []string
+	done := make(chan struct{})
+	go func() {
+		for d := range output {
+			domains = append(domains, d)
+		}
+		close(done)
+	}()
+	count, err := PredictDomainsWithRules(domain, rules, output)
+	close(output)
+	<-done
+	assert.NoError(t, err)
+	assert.Equal(t, count, len(domains))
+	return domains
 }
 
 func TestRealConfigFiles(t *testing.T) {
-	var buf output
-	count, err := PredictDomains("test.example.com", &buf)
+	rules, err := DefaultRules()
 	if err != nil {
 		t.Fatalf("使用实际配置文件进行域名预测失败: %v", err)
 	}
-	t.Log(count)
-	assert.Greater(t, count, 0)
+	domains := collect(t, "test.example.com", rules)
+	t.Log(len(domains))
+	assert.Greater(t, len(domains), 0)
+}
+
+func TestNumRange(t *testing.T) {
+	rules, err := ParseRules("node{num:01-03}.{domain}\nweb{num:9-10}.{domain}", "")
+	assert.NoError(t, err)
+	domains := collect(t, "example.com", rules)
+	assert.Equal(t, []string{
+		"node01.example.com", "node02.example.com", "node03.example.com",
+		"web9.example.com", "web10.example.com",
+	}, domains)
+}
+
+func TestTransforms(t *testing.T) {
+	rules, err := ParseRules("@insert {env}\n@replace {env}\n@increment 2", "[env]\ndev\n")
+	assert.NoError(t, err)
+	domains := collect(t, "api2.prod.example.com", rules)
+	assert.ElementsMatch(t, []string{
+		// insert
+		"dev.api2.prod.example.com", "api2.dev.prod.example.com", "api2.prod.dev.example.com",
+		// replace
+		"dev.prod.example.com", "api2.dev.example.com",
+		// increment
+		"api1.prod.example.com", "api3.prod.example.com", "api0.prod.example.com", "api4.prod.example.com",
+	}, domains)
+}
+
+func TestParseRulesErrors(t *testing.T) {
+	for _, cfg := range []string{
+		"{missing}.{domain}",
+		"{num:5-1}.{domain}",
+		"{subdomain.{domain",
+		"@insert",
+		"@increment 0",
+		"@shuffle",
+	} {
+		_, err := ParseRules(cfg, "[env]\ndev\n")
+		assert.Error(t, err, cfg)
+	}
+}
+
+func TestGenerateStop(t *testing.T) {
+	rules, err := ParseRules("node{num:1-100}.{domain}", "")
+	assert.NoError(t, err)
+	var domains []string
+	count := rules.Generate("example.com", func(domain string) bool {
+		if len(domains) == 10 {
+			return false
+		}
+		domains = append(domains, domain)
+		return true
+	})
+	assert.Equal(t, 10, count)
+	assert.Equal(t, "node10.example.com", domains[9])
 }
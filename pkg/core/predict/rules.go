@@ -0,0 +1,206 @@
+package predict
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Rules 预测规则
+// 模式配置每行一个模式，如 {environment}.{subdomain}.{domain}，支持数字范围标签 {num:1-20}；
+// 以 @ 开头的行为内置变换：
+//
+//	@insert {分类}   在已有标签之间插入分类中的词
+//	@replace {分类}  将已有标签逐个替换为分类中的词
+//	@increment [N]  将标签中的数字加减 1..N，默认为1
+//
+// 分类字典以 [分类] 开头，其后每行一个值，# 开头的行为注释
+type Rules struct {
+	Patterns   []string            // 域名组合模式
+	Transforms []Transform         // 内置变换
+	Categories map[string][]string // 存储块分类和对应的值
+}
+
+// Transform 内置变换
+type Transform struct {
+	Kind     string // insert, replace, increment
+	Category string // insert/replace 使用的分类
+	Step     int    // increment 的最大步长
+}
+
+// DefaultRules 返回内置的预测规则
+func DefaultRules() (*Rules, error) {
+	return ParseRules(cfg, dict)
+}
+
+// LoadRules 从文件加载预测规则，路径为空时使用对应的内置配置
+func LoadRules(cfgFile, dictFile string) (*Rules, error) {
+	cfgText, dictText := cfg, dict
+	if cfgFile != "" {
+		data, err := os.ReadFile(cfgFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取预测配置失败: %v", err)
+		}
+		cfgText = string(data)
+	}
+	if dictFile != "" {
+		data, err := os.ReadFile(dictFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取预测字典失败: %v", err)
+		}
+		dictText = string(data)
+	}
+	return ParseRules(cfgText, dictText)
+}
+
+// ParseRules 解析模式配置和分类字典
+func ParseRules(cfgText, dictText string) (*Rules, error) {
+	rules := &Rules{Categories: make(map[string][]string)}
+	if err := rules.parseDictionary(dictText); err != nil {
+		return nil, fmt.Errorf("加载字典文件失败: %v", err)
+	}
+	if err := rules.parsePatterns(cfgText); err != nil {
+		return nil, fmt.Errorf("加载配置文件失败: %v", err)
+	}
+	return rules, nil
+}
+
+// parseDictionary 解析分类字典
+func (r *Rules) parseDictionary(text string) error {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	var currentCategory string
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// 检查是否是分类标识 [category]
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			currentCategory = line[1 : len(line)-1]
+			r.Categories[currentCategory] = []string{}
+		} else if currentCategory != "" {
+			// 如果有当前分类，添加值
+			r.Categories[currentCategory] = append(r.Categories[currentCategory], line)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// parsePatterns 解析模式配置，检查标签和变换是否合法
+func (r *Rules) parsePatterns(text string) error {
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			t, err := r.parseTransform(line)
+			if err != nil {
+				return fmt.Errorf("第%d行: %v", n, err)
+			}
+			r.Transforms = append(r.Transforms, t)
+			continue
+		}
+		if err := r.checkPattern(line); err != nil {
+			return fmt.Errorf("第%d行: %v", n, err)
+		}
+		r.Patterns = append(r.Patterns, line)
+	}
+	return scanner.Err()
+}
+
+// checkPattern 检查模式中的标签是否都能被替换
+func (r *Rules) checkPattern(pattern string) error {
+	for {
+		startIdx := strings.Index(pattern, "{")
+		if startIdx == -1 {
+			return nil
+		}
+		endIdx := strings.Index(pattern[startIdx:], "}")
+		if endIdx == -1 {
+			return fmt.Errorf("标签未闭合: %s", pattern)
+		}
+		tag := pattern[startIdx+1 : startIdx+endIdx]
+		pattern = pattern[startIdx+endIdx+1:]
+		switch {
+		case tag == "subdomain" || tag == "domain":
+		case strings.HasPrefix(tag, numTagPrefix):
+			if _, err := parseNumRange(tag); err != nil {
+				return err
+			}
+		default:
+			if _, ok := r.Categories[tag]; !ok {
+				return fmt.Errorf("未知的分类: %s", tag)
+			}
+		}
+	}
+}
+
+// parseTransform 解析 @ 开头的内置变换
+func (r *Rules) parseTransform(line string) (Transform, error) {
+	fields := strings.Fields(line)
+	t := Transform{Kind: strings.TrimPrefix(fields[0], "@")}
+	switch t.Kind {
+	case "insert", "replace":
+		if len(fields) != 2 {
+			return t, fmt.Errorf("@%s 需要指定分类，如 @%s {prefix}", t.Kind, t.Kind)
+		}
+		t.Category = strings.TrimSuffix(strings.TrimPrefix(fields[1], "{"), "}")
+		if _, ok := r.Categories[t.Category]; !ok {
+			return t, fmt.Errorf("未知的分类: %s", t.Category)
+		}
+	case "increment":
+		t.Step = 1
+		if len(fields) > 1 {
+			step, err := strconv.Atoi(fields[1])
+			if err != nil || step <= 0 {
+				return t, fmt.Errorf("无效的步长: %s", fields[1])
+			}
+			t.Step = step
+		}
+	default:
+		return t, fmt.Errorf("未知的变换: %s", fields[0])
+	}
+	return t, nil
+}
+
+// numTagPrefix 数字范围标签前缀
+const numTagPrefix = "num:"
+
+// numRange 数字范围标签 {num:1-20}，起始值有前导0时按其宽度补0，如 {num:01-20}
+type numRange struct {
+	start, end, width int
+}
+
+func parseNumRange(tag string) (numRange, error) {
+	spec := strings.TrimPrefix(tag, numTagPrefix)
+	parts := strings.SplitN(spec, "-", 2)
+	if len(parts) != 2 {
+		return numRange{}, fmt.Errorf("无效的数字范围: {%s}", tag)
+	}
+	start, err1 := strconv.Atoi(parts[0])
+	end, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || start < 0 || end < start {
+		return numRange{}, fmt.Errorf("无效的数字范围: {%s}", tag)
+	}
+	nr := numRange{start: start, end: end}
+	if len(parts[0]) > 1 && parts[0][0] == '0' {
+		nr.width = len(parts[0])
+	}
+	return nr, nil
+}
+
+// values 返回范围内的全部数字
+func (nr numRange) values() []string {
+	values := make([]string, 0, nr.end-nr.start+1)
+	for i := nr.start; i <= nr.end; i++ {
+		values = append(values, fmt.Sprintf("%0*d", nr.width, i))
+	}
+	return values
+}
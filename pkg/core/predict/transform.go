@@ -0,0 +1,77 @@
+package predict
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// digitsRe 匹配标签中的数字
+var digitsRe = regexp.MustCompile(`\d+`)
+
+// transform 对基础域名的子域名标签执行内置变换，根域名(最后两级)保持不变
+func (dg *DomainGenerator) transform(t Transform) {
+	labels := strings.Split(dg.name, ".")
+	if len(labels) < 2 {
+		return
+	}
+	editable, root := labels[:len(labels)-2], strings.Join(labels[len(labels)-2:], ".")
+	join := func(parts []string) string {
+		if len(parts) == 0 {
+			return root
+		}
+		return strings.Join(parts, ".") + "." + root
+	}
+
+	switch t.Kind {
+	case "insert":
+		// 在每个位置插入分类中的词
+		for _, word := range dg.rules.Categories[t.Category] {
+			for pos := 0; pos <= len(editable); pos++ {
+				parts := make([]string, 0, len(editable)+1)
+				parts = append(parts, editable[:pos]...)
+				parts = append(parts, word)
+				parts = append(parts, editable[pos:]...)
+				dg.emit(join(parts))
+			}
+		}
+	case "replace":
+		// 将每个标签替换为分类中的词
+		for _, word := range dg.rules.Categories[t.Category] {
+			for i := range editable {
+				if editable[i] == word {
+					continue
+				}
+				parts := append([]string(nil), editable...)
+				parts[i] = word
+				dg.emit(join(parts))
+			}
+		}
+	case "increment":
+		// 将标签中的每段数字加减 1..Step
+		for i, label := range editable {
+			for _, loc := range digitsRe.FindAllStringIndex(label, -1) {
+				digits := label[loc[0]:loc[1]]
+				n, err := strconv.Atoi(digits)
+				if err != nil {
+					continue
+				}
+				width := 0
+				if len(digits) > 1 && digits[0] == '0' {
+					width = len(digits)
+				}
+				for step := 1; step <= t.Step; step++ {
+					for _, v := range []int{n - step, n + step} {
+						if v < 0 {
+							continue
+						}
+						parts := append([]string(nil), editable...)
+						parts[i] = label[:loc[0]] + fmt.Sprintf("%0*d", width, v) + label[loc[1]:]
+						dg.emit(join(parts))
+					}
+				}
+			}
+		}
+	}
+}
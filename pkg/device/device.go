@@ -5,17 +5,28 @@ import (
 	"os"
 	"time"
 
-	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/google/gopacket/pcap"
 	"gopkg.in/yaml.v3"
 )
 
 // EtherTable 存储网卡信息的数据结构
 type EtherTable struct {
-	SrcIp  net.IP  `yaml:"src_ip"`  // 源IP地址
-	Device string  `yaml:"device"`  // 网卡设备名称
-	SrcMac SelfMac `yaml:"src_mac"` // 源MAC地址
-	DstMac SelfMac `yaml:"dst_mac"` // 目标MAC地址（通常是网关）
+	SrcIp   net.IP  `yaml:"src_ip"`             // 源IP地址
+	Device  string  `yaml:"device"`             // 网卡设备名称
+	SrcMac  SelfMac `yaml:"src_mac"`            // 源MAC地址
+	DstMac  SelfMac `yaml:"dst_mac"`            // 目标MAC地址（通常是网关）
+	SrcIp6  net.IP  `yaml:"src_ip6,omitempty"`  // IPv6源地址，为空时不支持IPv6解析器
+	DstMac6 SelfMac `yaml:"dst_mac6,omitempty"` // IPv6路由器的MAC地址，非以太网链路为空；同一链路的IPv6解析器从邻居表获取MAC
+}
+
+// SupportsIPv4 是否可以向IPv4解析器发包
+func (e *EtherTable) SupportsIPv4() bool {
+	return e.SrcIp.To4() != nil
+}
+
+// SupportsIPv6 是否可以向IPv6解析器发包，以太网链路还需要 DstMac6
+func (e *EtherTable) SupportsIPv6() bool {
+	return e.SrcIp6 != nil && e.SrcIp6.To4() == nil
 }
 
 // ReadConfig 从文件读取EtherTable配置
@@ -49,8 +60,7 @@ func PcapInit(devicename string) (*pcap.Handle, error) {
 	)
 	handle, err := pcap.OpenLive(devicename, snapshot_len, false, timeout)
 	if err != nil {
-		gologger.Fatalf("pcap初始化失败:%s\n", err.Error())
-		return nil, err
+		return nil, pcapError("pcap初始化失败", err)
 	}
 	return handle, nil
 }
@@ -0,0 +1,29 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 网卡相关的错误类型，可使用 errors.Is 判断
+var (
+	ErrNoDevice        = errors.New("未发现可用的IPv4网卡")
+	ErrDeviceNotFound  = errors.New("网卡不存在")
+	ErrPermission      = errors.New("没有抓包权限，请使用 root 权限运行或授予 CAP_NET_RAW 权限")
+	ErrDetectTimeout   = errors.New("获取网络设备超时，请尝试手动指定网卡")
+	ErrNoValidDNS      = errors.New("没有找到有效DNS，无法进行测试")
+	ErrUnsupportedLink = errors.New("不支持的网卡链路类型")
+)
+
+// pcapError 将 pcap 返回的错误归类为上面的错误类型，无法归类时保留原始错误
+func pcapError(action string, err error) error {
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "permission") || strings.Contains(msg, "not permitted"):
+		return fmt.Errorf("%s: %w (%v)", action, ErrPermission, err)
+	case strings.Contains(msg, "no such device") || strings.Contains(msg, "doesn't exist"):
+		return fmt.Errorf("%s: %w (%v)", action, ErrDeviceNotFound, err)
+	}
+	return fmt.Errorf("%s: %v", action, err)
+}
@@ -0,0 +1,20 @@
+package device
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPcapError(t *testing.T) {
+	err := pcapError("pcap初始化失败", errors.New("eth0: You don't have permission to capture on that device (socket: Operation not permitted)"))
+	assert.True(t, errors.Is(err, ErrPermission))
+
+	err = pcapError("pcap初始化失败", errors.New("eth9: No such device exists (SIOCGIFHWADDR: No such device)"))
+	assert.True(t, errors.Is(err, ErrDeviceNotFound))
+
+	err = pcapError("pcap初始化失败", errors.New("something else"))
+	assert.False(t, errors.Is(err, ErrPermission))
+	assert.Contains(t, err.Error(), "something else")
+}
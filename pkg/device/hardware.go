@@ -22,6 +22,11 @@ func (d SelfMac) HardwareAddr() net.HardwareAddr {
 }
 func (d *SelfMac) UnmarshalYAML(value *yaml.Node) error {
 	v := value.Value
+	if v == "" {
+		// 非以太网链路没有MAC地址
+		*d = nil
+		return nil
+	}
 	v2, err := net.ParseMAC(v)
 	if err != nil {
 		return err
@@ -34,7 +39,19 @@ func (d *SelfMac) UnmarshalYAML(value *yaml.Node) error {
 // 打印设备信息
 func PrintDeviceInfo(ether *EtherTable) {
 	gologger.Infof("Device: %s\n", ether.Device)
-	gologger.Infof("IP: %s\n", ether.SrcIp.String())
-	gologger.Infof("Local Mac: %s\n", ether.SrcMac.String())
-	gologger.Infof("Gateway Mac: %s\n", ether.DstMac.String())
+	if ether.SupportsIPv4() {
+		gologger.Infof("IP: %s\n", ether.SrcIp.String())
+	}
+	if len(ether.SrcMac) > 0 {
+		gologger.Infof("Local Mac: %s\n", ether.SrcMac.String())
+	}
+	if ether.SupportsIPv4() && len(ether.DstMac) > 0 {
+		gologger.Infof("Gateway Mac: %s\n", ether.DstMac.String())
+	}
+	if ether.SupportsIPv6() {
+		gologger.Infof("IPv6: %s\n", ether.SrcIp6.String())
+		if len(ether.DstMac6) > 0 {
+			gologger.Infof("IPv6 Router Mac: %s\n", ether.DstMac6.String())
+		}
+	}
 }
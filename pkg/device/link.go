@@ -0,0 +1,118 @@
+package device
+
+import (
+	"fmt"
+	"runtime"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Framing 抓包句柄的链路层封装方式，决定发包时的链路头和解码时的首层
+type Framing int
+
+const (
+	FramingEthernet Framing = iota // 以太网，需要源/目的MAC
+	FramingNull                    // BSD loopback(DLT_NULL)，4字节主机字节序地址族
+	FramingLoop                    // OpenBSD loopback(DLT_LOOP)，4字节网络字节序地址族
+	FramingSLL                     // Linux cooked capture(DLT_LINUX_SLL)
+	FramingRaw                     // 原始IP(tun、WireGuard 等)，没有链路层头
+)
+
+func (f Framing) String() string {
+	switch f {
+	case FramingEthernet:
+		return "Ethernet"
+	case FramingNull:
+		return "Null"
+	case FramingLoop:
+		return "Loop"
+	case FramingSLL:
+		return "Linux SLL"
+	case FramingRaw:
+		return "Raw IP"
+	}
+	return fmt.Sprintf("Framing(%d)", int(f))
+}
+
+// LinkFraming 根据 pcap 句柄的 LinkType() 返回链路封装方式
+// DLT_RAW 在多数系统上为12，OpenBSD 上为14(12为DLT_LOOP)，pcap 文件格式中为101
+func LinkFraming(link layers.LinkType) (Framing, error) {
+	switch link {
+	case layers.LinkTypeEthernet:
+		return FramingEthernet, nil
+	case layers.LinkTypeNull:
+		return FramingNull, nil
+	case layers.LinkTypeLoop:
+		return FramingLoop, nil
+	case layers.LinkTypeLinuxSLL:
+		return FramingSLL, nil
+	case layers.LinkTypeRaw, layers.LinkTypeIPv4, layers.LinkTypeIPv6:
+		return FramingRaw, nil
+	case 12:
+		if runtime.GOOS == "openbsd" {
+			return FramingLoop, nil
+		}
+		return FramingRaw, nil
+	case 14:
+		if runtime.GOOS == "openbsd" {
+			return FramingRaw, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %s(%d)", ErrUnsupportedLink, link, int(link))
+}
+
+// LinkParser 按链路封装方式选择首个解码层，原始IP链路根据IP版本号选择IPv4或IPv6
+type LinkParser struct {
+	framing Framing
+	parsers map[gopacket.LayerType]*gopacket.DecodingLayerParser
+}
+
+// NewLinkParser 创建解析器，decoders 需包含链路封装对应的首层(Ethernet、Loopback、LinuxSLL 或 IPv4/IPv6)
+// 遇到没有解码器的下一层时停止解码而不返回错误，调用方根据已解码的层判断
+func NewLinkParser(framing Framing, decoders ...gopacket.DecodingLayer) *LinkParser {
+	var firsts []gopacket.LayerType
+	switch framing {
+	case FramingEthernet:
+		firsts = []gopacket.LayerType{layers.LayerTypeEthernet}
+	case FramingNull, FramingLoop:
+		firsts = []gopacket.LayerType{layers.LayerTypeLoopback}
+	case FramingSLL:
+		firsts = []gopacket.LayerType{layers.LayerTypeLinuxSLL}
+	default:
+		firsts = []gopacket.LayerType{layers.LayerTypeIPv4, layers.LayerTypeIPv6}
+	}
+	p := &LinkParser{
+		framing: framing,
+		parsers: make(map[gopacket.LayerType]*gopacket.DecodingLayerParser, len(firsts)),
+	}
+	for _, first := range firsts {
+		parser := gopacket.NewDecodingLayerParser(first, decoders...)
+		parser.IgnoreUnsupported = true
+		p.parsers[first] = parser
+	}
+	return p
+}
+
+// DecodeLayers 解码数据包，用法与 gopacket.DecodingLayerParser 相同
+func (p *LinkParser) DecodeLayers(data []byte, decoded *[]gopacket.LayerType) error {
+	switch p.framing {
+	case FramingEthernet:
+		return p.parsers[layers.LayerTypeEthernet].DecodeLayers(data, decoded)
+	case FramingNull, FramingLoop:
+		return p.parsers[layers.LayerTypeLoopback].DecodeLayers(data, decoded)
+	case FramingSLL:
+		return p.parsers[layers.LayerTypeLinuxSLL].DecodeLayers(data, decoded)
+	}
+	*decoded = (*decoded)[:0]
+	if len(data) == 0 {
+		return fmt.Errorf("空数据包")
+	}
+	switch data[0] >> 4 {
+	case 4:
+		return p.parsers[layers.LayerTypeIPv4].DecodeLayers(data, decoded)
+	case 6:
+		return p.parsers[layers.LayerTypeIPv6].DecodeLayers(data, decoded)
+	}
+	return fmt.Errorf("未知的IP版本: %d", data[0]>>4)
+}
@@ -0,0 +1,26 @@
+package device
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLinkFraming(t *testing.T) {
+	framing, err := LinkFraming(layers.LinkTypeEthernet)
+	assert.NoError(t, err)
+	assert.Equal(t, FramingEthernet, framing)
+
+	framing, err = LinkFraming(layers.LinkTypeRaw)
+	assert.NoError(t, err)
+	assert.Equal(t, FramingRaw, framing)
+
+	framing, err = LinkFraming(layers.LinkTypeLinuxSLL)
+	assert.NoError(t, err)
+	assert.Equal(t, FramingSLL, framing)
+
+	_, err = LinkFraming(layers.LinkTypeIEEE802_11)
+	assert.True(t, errors.Is(err, ErrUnsupportedLink))
+}
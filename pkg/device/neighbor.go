@@ -0,0 +1,73 @@
+package device
+
+import (
+	"net"
+	"sync"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+)
+
+// Neighbors 选择以太网上发往IPv6地址的下一跳MAC：经路由器转发的地址使用 DstMac6；
+// 与本机在同一链路(链路本地地址或在网卡的IPv6前缀内)的地址不经过路由器，从系统邻居表查询其MAC
+type Neighbors struct {
+	device   string
+	router   net.HardwareAddr
+	prefixes []*net.IPNet
+	lookup   func(device string, ip net.IP) (net.HardwareAddr, error)
+
+	mu    sync.Mutex
+	cache map[string]net.HardwareAddr // 值为nil表示邻居表中没有该地址
+}
+
+// NewNeighbors 读取网卡的IPv6前缀，网卡不存在时只有链路本地地址视为同一链路
+func NewNeighbors(ether *EtherTable) *Neighbors {
+	n := &Neighbors{
+		device: ether.Device,
+		router: ether.DstMac6.HardwareAddr(),
+		lookup: lookupNeighbor6,
+		cache:  make(map[string]net.HardwareAddr),
+	}
+	if iface, err := net.InterfaceByName(ether.Device); err == nil {
+		addrs, _ := iface.Addrs()
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil {
+				n.prefixes = append(n.prefixes, ipNet)
+			}
+		}
+	}
+	return n
+}
+
+// onLink 地址是否与本机在同一链路
+func (n *Neighbors) onLink(ip net.IP) bool {
+	if ip.IsLinkLocalUnicast() {
+		return true
+	}
+	for _, prefix := range n.prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextHop6 返回发往 ip 的下一跳MAC，同一链路的地址不在邻居表中时返回false，每个地址只提示一次
+func (n *Neighbors) NextHop6(ip net.IP) (net.HardwareAddr, bool) {
+	if !n.onLink(ip) {
+		return n.router, len(n.router) > 0
+	}
+	key := ip.String()
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if mac, ok := n.cache[key]; ok {
+		return mac, mac != nil
+	}
+	mac, err := n.lookup(n.device, ip)
+	if err != nil {
+		gologger.Warningf("IPv6解析器 %s 与本机在同一链路，无法从邻居表获取其MAC(%v)，已跳过；只支持经路由器转发的IPv6解析器\n", key, err)
+		n.cache[key] = nil
+		return nil, false
+	}
+	n.cache[key] = mac
+	return mac, true
+}
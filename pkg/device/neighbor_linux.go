@@ -0,0 +1,81 @@
+//go:build linux
+
+package device
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"syscall"
+)
+
+const (
+	ndaDst          = 1    // NDA_DST
+	ndaLLAddr       = 2    // NDA_LLADDR
+	nudIncomplete   = 0x01 // NUD_INCOMPLETE
+	nudFailed       = 0x20 // NUD_FAILED
+	ndMsgLen        = 12   // sizeof(struct ndmsg)
+	rtAttrHeaderLen = 4
+)
+
+// lookupNeighbor6 通过 netlink 读取内核的IPv6邻居表，返回网卡上 ip 的MAC
+func lookupNeighbor6(device string, ip net.IP) (net.HardwareAddr, error) {
+	iface, err := net.InterfaceByName(device)
+	if err != nil {
+		return nil, err
+	}
+	tab, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, err
+	}
+	msgs, err := syscall.ParseNetlinkMessage(tab)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range msgs {
+		if m.Header.Type != syscall.RTM_NEWNEIGH {
+			continue
+		}
+		if mac, ok := parseNeighbor(m.Data, iface.Index, ip); ok {
+			return mac, nil
+		}
+	}
+	return nil, errors.New("邻居表中没有该地址")
+}
+
+// parseNeighbor 解析一条 RTM_NEWNEIGH 消息，网卡和地址匹配且表项可用时返回MAC
+func parseNeighbor(data []byte, ifIndex int, ip net.IP) (net.HardwareAddr, bool) {
+	if len(data) < ndMsgLen {
+		return nil, false
+	}
+	if int(int32(binary.NativeEndian.Uint32(data[4:8]))) != ifIndex {
+		return nil, false
+	}
+	if state := binary.NativeEndian.Uint16(data[8:10]); state&(nudIncomplete|nudFailed) != 0 {
+		return nil, false
+	}
+	var dst net.IP
+	var mac net.HardwareAddr
+	for attrs := data[ndMsgLen:]; len(attrs) >= rtAttrHeaderLen; {
+		l := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		if l < rtAttrHeaderLen || l > len(attrs) {
+			break
+		}
+		value := attrs[rtAttrHeaderLen:l]
+		switch binary.NativeEndian.Uint16(attrs[2:4]) {
+		case ndaDst:
+			dst = net.IP(value)
+		case ndaLLAddr:
+			mac = net.HardwareAddr(value)
+		}
+		aligned := (l + 3) &^ 3
+		if aligned > len(attrs) {
+			break
+		}
+		attrs = attrs[aligned:]
+	}
+	if len(mac) != 6 || !dst.Equal(ip) {
+		return nil, false
+	}
+	return append(net.HardwareAddr(nil), mac...), true
+}
@@ -0,0 +1,49 @@
+package device
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// neighborMsg 构造一条 RTM_NEWNEIGH 消息体
+func neighborMsg(ifIndex int, state uint16, ip net.IP, mac net.HardwareAddr) []byte {
+	data := make([]byte, ndMsgLen)
+	data[0] = 10 // AF_INET6
+	binary.NativeEndian.PutUint32(data[4:8], uint32(ifIndex))
+	binary.NativeEndian.PutUint16(data[8:10], state)
+	for _, attr := range []struct {
+		typ   uint16
+		value []byte
+	}{{ndaDst, ip.To16()}, {ndaLLAddr, mac}} {
+		header := make([]byte, rtAttrHeaderLen)
+		binary.NativeEndian.PutUint16(header[0:2], uint16(rtAttrHeaderLen+len(attr.value)))
+		binary.NativeEndian.PutUint16(header[2:4], attr.typ)
+		data = append(data, header...)
+		data = append(data, attr.value...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	return data
+}
+
+func TestParseNeighbor(t *testing.T) {
+	ip := net.ParseIP("2001:db8:1::53")
+	mac := net.HardwareAddr{6, 5, 4, 3, 2, 1}
+
+	got, ok := parseNeighbor(neighborMsg(2, 0x02, ip, mac), 2, ip)
+	assert.True(t, ok)
+	assert.Equal(t, mac, got)
+
+	_, ok = parseNeighbor(neighborMsg(3, 0x02, ip, mac), 2, ip)
+	assert.False(t, ok)
+	_, ok = parseNeighbor(neighborMsg(2, nudFailed, ip, mac), 2, ip)
+	assert.False(t, ok)
+	_, ok = parseNeighbor(neighborMsg(2, 0x02, net.ParseIP("2001:db8:1::54"), mac), 2, ip)
+	assert.False(t, ok)
+	_, ok = parseNeighbor(neighborMsg(2, 0x02, ip, mac)[:ndMsgLen-1], 2, ip)
+	assert.False(t, ok)
+}
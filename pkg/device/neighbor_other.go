@@ -0,0 +1,13 @@
+//go:build !linux
+
+package device
+
+import (
+	"errors"
+	"net"
+)
+
+// lookupNeighbor6 当前系统不支持读取邻居表
+func lookupNeighbor6(device string, ip net.IP) (net.HardwareAddr, error) {
+	return nil, errors.New("当前系统不支持读取邻居表")
+}
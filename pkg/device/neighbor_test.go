@@ -0,0 +1,47 @@
+package device
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNeighborsNextHop6(t *testing.T) {
+	_, prefix, _ := net.ParseCIDR("2001:db8:1::/64")
+	lookups := 0
+	n := &Neighbors{
+		device:   "eth0",
+		router:   net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		prefixes: []*net.IPNet{prefix},
+		cache:    make(map[string]net.HardwareAddr),
+		lookup: func(device string, ip net.IP) (net.HardwareAddr, error) {
+			lookups++
+			if ip.Equal(net.ParseIP("2001:db8:1::53")) {
+				return net.HardwareAddr{6, 5, 4, 3, 2, 1}, nil
+			}
+			return nil, errors.New("not found")
+		},
+	}
+
+	// 经路由器转发的地址使用路由器的MAC
+	mac, ok := n.NextHop6(net.ParseIP("2606:4700:4700::1111"))
+	assert.True(t, ok)
+	assert.Equal(t, n.router, mac)
+
+	// 同一链路的地址从邻居表查询，结果被缓存
+	mac, ok = n.NextHop6(net.ParseIP("2001:db8:1::53"))
+	assert.True(t, ok)
+	assert.Equal(t, net.HardwareAddr{6, 5, 4, 3, 2, 1}, mac)
+	_, _ = n.NextHop6(net.ParseIP("2001:db8:1::53"))
+
+	// 不在邻居表中的同一链路地址和链路本地地址被跳过
+	_, ok = n.NextHop6(net.ParseIP("2001:db8:1::54"))
+	assert.False(t, ok)
+	_, ok = n.NextHop6(net.ParseIP("fe80::1"))
+	assert.False(t, ok)
+	_, ok = n.NextHop6(net.ParseIP("fe80::1"))
+	assert.False(t, ok)
+	assert.Equal(t, 3, lookups)
+}
@@ -18,28 +18,43 @@ import (
 )
 
 // 获取所有IPv4网卡信息
-func GetAllIPv4Devices() ([]string, map[string]net.IP) {
+func GetAllIPv4Devices() ([]string, map[string]net.IP, error) {
+	return getDevices(func(ip net.IP) bool {
+		return ip.To4() != nil
+	})
+}
+
+// GetAllIPv6Devices 获取所有拥有全局单播或环回IPv6地址的网卡信息
+func GetAllIPv6Devices() ([]string, map[string]net.IP, error) {
+	return getDevices(func(ip net.IP) bool {
+		return ip.To4() == nil && (ip.IsGlobalUnicast() || ip.IsLoopback())
+	})
+}
+
+// getDevices 返回拥有满足 match 的地址的网卡，每块网卡取第一个匹配的地址
+func getDevices(match func(ip net.IP) bool) ([]string, map[string]net.IP, error) {
 	devices, err := pcap.FindAllDevs()
 	deviceNames := []string{}
 	deviceMap := make(map[string]net.IP)
 
 	if err != nil {
-		gologger.Fatalf("获取网络设备失败: %s\n", err.Error())
-		return deviceNames, deviceMap
+		return deviceNames, deviceMap, pcapError("获取网络设备失败", err)
 	}
 
 	for _, d := range devices {
 		for _, address := range d.Addresses {
 			ip := address.IP
-			// 只保留IPv4且非回环地址
-			if ip.To4() != nil {
+			if !match(ip) {
+				continue
+			}
+			if _, ok := deviceMap[d.Name]; !ok {
 				deviceMap[d.Name] = ip
 				deviceNames = append(deviceNames, d.Name)
 			}
 		}
 	}
 
-	return deviceNames, deviceMap
+	return deviceNames, deviceMap, nil
 }
 
 func ValidDNS(dns string) bool {
@@ -87,23 +102,86 @@ func AutoGetDevices(userDNS []string) (*EtherTable, error) {
 	}
 
 	if len(validDNS) == 0 {
-		return nil, fmt.Errorf("没有找到有效DNS，无法进行测试")
+		return nil, ErrNoValidDNS
 	}
 
 	gologger.Infof("使用以下DNS服务器进行测试: %v\n", validDNS)
-	return AutoGetDevicesWithDNS(validDNS), nil
+	return AutoGetDevicesWithDNS(validDNS)
 }
 
 // AutoGetDevicesWithDNS 使用指定DNS自动获取外网发包网卡
-// 如果传入的DNS无效，则尝试使用系统DNS
-func AutoGetDevicesWithDNS(validDNS []string) *EtherTable {
-	// 获取所有IPv4网卡
-	deviceNames, _ := GetAllIPv4Devices()
-	if len(deviceNames) == 0 {
-		gologger.Fatalf("未发现可用的IPv4网卡\n")
-		return nil
+// IPv4 和 IPv6 解析器分别用于识别对应协议的源地址和下一跳MAC，任一协议识别成功即可
+func AutoGetDevicesWithDNS(validDNS []string) (*EtherTable, error) {
+	var dns4, dns6 []string
+	for _, server := range validDNS {
+		if isIPv6Server(server) {
+			dns6 = append(dns6, server)
+		} else {
+			dns4 = append(dns4, server)
+		}
 	}
 
+	var ether *EtherTable
+	var err error
+	if len(dns4) > 0 {
+		var deviceNames []string
+		deviceNames, _, err = GetAllIPv4Devices()
+		if err == nil && len(deviceNames) == 0 {
+			err = ErrNoDevice
+		}
+		if err == nil {
+			ether, err = detectDevice(deviceNames, dns4, 30)
+		}
+	}
+	if len(dns6) == 0 {
+		return ether, err
+	}
+
+	// 已识别IPv4时IPv6只做补充，缩短等待时间
+	timeout := 30
+	if ether != nil {
+		timeout = 10
+	}
+	deviceNames, _, err6 := GetAllIPv6Devices()
+	if err6 == nil && len(deviceNames) == 0 {
+		err6 = ErrNoDevice
+	}
+	var ether6 *EtherTable
+	if err6 == nil {
+		ether6, err6 = detectDevice(deviceNames, dns6, timeout)
+	}
+	switch {
+	case ether6 == nil:
+		if ether == nil {
+			if err == nil {
+				err = err6
+			}
+			return nil, err
+		}
+		gologger.Warningf("识别IPv6出口失败，将不使用IPv6解析器: %v\n", err6)
+	case ether == nil:
+		ether = ether6
+	case ether.Device == ether6.Device:
+		ether.SrcIp6 = ether6.SrcIp6
+		ether.DstMac6 = ether6.DstMac6
+	default:
+		gologger.Warningf("IPv6出口网卡 %s 与IPv4出口网卡 %s 不同，将不使用IPv6解析器\n", ether6.Device, ether.Device)
+	}
+	return ether, nil
+}
+
+// isIPv6Server 解析器地址是否为IPv6
+func isIPv6Server(server string) bool {
+	host := server
+	if h, _, err := net.SplitHostPort(server); err == nil {
+		host = h
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.To4() == nil
+}
+
+// detectDevice 通过系统协议栈向 dnsServers 查询随机域名，在各网卡上抓取响应以识别出口网卡
+func detectDevice(deviceNames []string, dnsServers []string, timeout int) (*EtherTable, error) {
 	// 创建随机域名用于测试
 	domain := core.RandomStr(6) + ".baidu.com"
 	signal := make(chan *EtherTable)
@@ -113,14 +191,12 @@ func AutoGetDevicesWithDNS(validDNS []string) *EtherTable {
 	defer cancel()
 
 	// 测试所有网卡
-	activeDeviceCount := 0
 	for _, deviceName := range deviceNames {
 		gologger.Infof("正在测试网卡 %s 的连通性...\n", deviceName)
 		go testDeviceConnectivity(ctx, deviceName, domain, signal)
-		activeDeviceCount++
 	}
 	// 等待测试结果或超时
-	return waitForDeviceTest(signal, domain, validDNS, 30)
+	return waitForDeviceTest(signal, domain, dnsServers, timeout)
 }
 
 // 测试网卡连通性
@@ -138,26 +214,32 @@ func testDeviceConnectivity(ctx context.Context, deviceName string, domain strin
 	}
 	defer handle.Close()
 
-	// 添加BPF过滤器，只捕获DNS响应包
-	err = handle.SetBPFFilter("udp port 53")
+	// 添加BPF过滤器，只捕获UDP包（解析器可能不在53端口，由后续的DNS解码和域名匹配筛选）
+	err = handle.SetBPFFilter("udp")
 	if err != nil {
 		gologger.Debugf("设置过滤器失败 %s: %s\n", deviceName, err.Error())
 		// 继续尝试，不直接返回
 	}
 
+	framing, err := LinkFraming(handle.LinkType())
+	if err != nil {
+		gologger.Debugf("跳过网卡 %s: %s\n", deviceName, err.Error())
+		return
+	}
+	var udp layers.UDP
+	var dns layers.DNS
+	var eth layers.Ethernet
+	var loop layers.Loopback
+	var sll layers.LinuxSLL
+	var ipv4 layers.IPv4
+	var ipv6 layers.IPv6
+	parser := NewLinkParser(framing, &eth, &loop, &sll, &ipv4, &ipv6, &udp)
+
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			var udp layers.UDP
-			var dns layers.DNS
-			var eth layers.Ethernet
-			var ipv4 layers.IPv4
-
-			parser := gopacket.NewDecodingLayerParser(
-				layers.LayerTypeEthernet, &eth, &ipv4, &udp, &dns)
-
 			data, _, err := handle.ReadPacketData()
 			if err != nil {
 				if errors.Is(err, pcap.NextErrorTimeoutExpired) {
@@ -172,15 +254,19 @@ func testDeviceConnectivity(ctx context.Context, deviceName string, domain strin
 				continue
 			}
 
-			// 检查是否解析到DNS层
-			dnsFound := false
+			// 检查是否解析到UDP层，并记录网络层协议
+			udpFound := false
+			isIPv6 := false
 			for _, layerType := range decoded {
-				if layerType == layers.LayerTypeDNS {
-					dnsFound = true
-					break
+				switch layerType {
+				case layers.LayerTypeUDP:
+					udpFound = true
+				case layers.LayerTypeIPv6:
+					isIPv6 = true
 				}
 			}
-			if !dnsFound {
+			// gopacket 只把53端口的负载识别为DNS，从UDP负载解码以支持其他端口
+			if !udpFound || dns.DecodeFromBytes(udp.Payload, gopacket.NilDecodeFeedback) != nil {
 				continue
 			}
 
@@ -194,13 +280,25 @@ func testDeviceConnectivity(ctx context.Context, deviceName string, domain strin
 				questionName := string(q.Name)
 				gologger.Debugf("收到DNS响应 %s，域名: %s\n", deviceName, questionName)
 				if questionName == domain || questionName == domain+"." {
-					etherTable := EtherTable{
-						SrcIp:  ipv4.DstIP,
-						Device: deviceName,
-						SrcMac: SelfMac(eth.DstMAC),
-						DstMac: SelfMac(eth.SrcMAC),
+					etherTable := EtherTable{Device: deviceName}
+					if isIPv6 {
+						etherTable.SrcIp6 = append(net.IP(nil), ipv6.DstIP...)
+					} else {
+						etherTable.SrcIp = append(net.IP(nil), ipv4.DstIP...)
+					}
+					// 以太网上响应的来源MAC即下一跳的MAC，IPv6为路由器的邻居MAC；其他链路发包不需要MAC
+					if framing == FramingEthernet {
+						etherTable.SrcMac = SelfMac(append(net.HardwareAddr(nil), eth.DstMAC...))
+						if isIPv6 {
+							etherTable.DstMac6 = SelfMac(append(net.HardwareAddr(nil), eth.SrcMAC...))
+						} else {
+							etherTable.DstMac = SelfMac(append(net.HardwareAddr(nil), eth.SrcMAC...))
+						}
+					}
+					select {
+					case signal <- &etherTable:
+					case <-ctx.Done():
 					}
-					signal <- &etherTable
 					return
 				}
 			}
@@ -209,7 +307,7 @@ func testDeviceConnectivity(ctx context.Context, deviceName string, domain strin
 }
 
 // 等待设备测试结果
-func waitForDeviceTest(signal <-chan *EtherTable, domain string, dnsServers []string, timeout int) *EtherTable {
+func waitForDeviceTest(signal <-chan *EtherTable, domain string, dnsServers []string, timeout int) (*EtherTable, error) {
 	ticker := time.NewTicker(time.Second)
 	defer ticker.Stop()
 
@@ -221,7 +319,7 @@ func waitForDeviceTest(signal <-chan *EtherTable, domain string, dnsServers []st
 		select {
 		case result := <-signal:
 			gologger.Infof("成功获取到外网网卡: %s\n", result.Device)
-			return result
+			return result, nil
 		case <-ticker.C:
 			// 每秒尝试一次DNS查询，轮换使用不同的DNS服务器
 			currentDNS := dnsServers[dnsIndex]
@@ -240,8 +338,7 @@ func waitForDeviceTest(signal <-chan *EtherTable, domain string, dnsServers []st
 			count++
 
 			if count >= timeout {
-				gologger.Fatalf("获取网络设备超时，请尝试手动指定网卡\n")
-				return nil
+				return nil, ErrDetectTimeout
 			}
 		}
 	}
@@ -253,7 +350,11 @@ func LookUpIP(fqdn, serverAddr string) (net.IP, error) {
 	client := dns.Client{}
 	client.Timeout = time.Second
 	m.SetQuestion(dns.Fqdn(fqdn), dns.TypeA)
-	r, _, err := client.Exchange(&m, serverAddr+":53")
+	// 未指定端口时使用53端口
+	if _, _, err := net.SplitHostPort(serverAddr); err != nil {
+		serverAddr = net.JoinHostPort(serverAddr, "53")
+	}
+	r, _, err := client.Exchange(&m, serverAddr)
 
 	if err != nil {
 		return nil, err
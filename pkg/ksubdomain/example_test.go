@@ -0,0 +1,63 @@
+package ksubdomain_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/ksubdomain"
+)
+
+// 验证一组域名，需要网卡发包权限
+func Example() {
+	scanner, err := ksubdomain.New(ksubdomain.NewOptions().
+		WithBandwidth("5M").
+		WithResolvers("1.1.1.1", "8.8.8.8").
+		WithQueryTypes("a", "cname"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	results, err := scanner.Scan(ctx, []string{"www.example.com", "mail.example.com"})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for res := range results {
+		fmt.Println(res.Subdomain, res.Answers)
+	}
+}
+
+// 使用内置字典枚举子域名，并递归枚举下一级
+func ExampleScanner_Enum() {
+	scanner, err := ksubdomain.New(ksubdomain.NewOptions().WithDepth(2, nil))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	results, err := scanner.Enum(context.Background(), []string{"example.com"}, nil)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	for res := range results {
+		fmt.Println(res.Subdomain)
+	}
+}
+
+// 参数错误在 New 时返回，不会退出进程
+func ExampleNew() {
+	_, err := ksubdomain.New(ksubdomain.NewOptions().
+		WithBandwidth("5X").
+		WithTimeout(3))
+	fmt.Println(err)
+
+	_, err = ksubdomain.New(ksubdomain.NewOptions().WithWildcardFilter("strict"))
+	fmt.Println(err != nil)
+	// Output:
+	// 带宽格式错误: unknown bandwith suffix 'X' (supported suffixes are G,M and K)
+	// true
+}
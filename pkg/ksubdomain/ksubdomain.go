@@ -0,0 +1,164 @@
+// Package ksubdomain 提供嵌入扫描器的稳定接口。
+//
+// 使用 NewOptions 构造参数，New 创建 Scanner 后调用 Scan 验证域名或 Enum 枚举子域名，
+// 结果从返回的通道中读取，扫描结束或 ctx 取消后通道关闭。所有错误都通过返回值传递，不会退出进程，
+// 可使用 errors.Is 判断 device.ErrPermission、device.ErrNoDevice、device.ErrDetectTimeout、options.ErrBandwidth 等错误类型；
+// 同一进程中可以创建多个 Scanner 并同时扫描，同一网卡上的扫描共享一个抓包句柄，
+// 按各自的监听端口和随机DNS ID分发响应。日志默认丢弃，可通过 Options.WithLogger 指定输出。
+package ksubdomain
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+)
+
+// Result 一个域名的解析结果
+type Result = result.Result
+
+// Record 一条解析记录
+type Record = result.Record
+
+// Scanner 扫描器，参数在创建后不再改变，可并发调用 Scan 和 Enum
+type Scanner struct {
+	opts  Options
+	ether *device.EtherTable
+}
+
+// New 校验参数并创建扫描器，pcap 发包方式未指定网卡时自动识别外网网卡，加密解析器不需要网卡
+func New(opts *Options) (*Scanner, error) {
+	if opts == nil {
+		opts = NewOptions()
+	}
+	if opts.err != nil {
+		return nil, opts.err
+	}
+	// 作为库使用时默认不输出日志
+	logOutput := opts.logOutput
+	if logOutput == nil {
+		logOutput = io.Discard
+	}
+	gologger.SetOutput(logOutput)
+	s := &Scanner{opts: *opts, ether: opts.ether}
+	if s.ether == nil && opts.transport != options.TransportUDP && !options.HasEncryptedResolver(opts.resolvers) {
+		ether, err := device.AutoGetDevices(opts.resolvers)
+		if err != nil {
+			return nil, err
+		}
+		s.ether = ether
+	}
+	return s, nil
+}
+
+// Device 返回扫描使用的网卡信息，udp 发包方式或加密解析器时为nil
+func (s *Scanner) Device() *device.EtherTable {
+	return s.ether
+}
+
+// Scan 验证给定的域名是否存在
+func (s *Scanner) Scan(ctx context.Context, domains []string) (<-chan Result, error) {
+	return s.run(ctx, options.VerifyType, nil, func(emit func(string) bool) {
+		for _, domain := range domains {
+			if !emit(domain) {
+				return
+			}
+		}
+	})
+}
+
+// Enum 使用字典枚举根域名的子域名，words 为空时使用内置字典
+func (s *Scanner) Enum(ctx context.Context, domains []string, words []string) (<-chan Result, error) {
+	if len(words) == 0 {
+		words = core.GetDefaultSubdomainData()
+	}
+	return s.run(ctx, options.EnumType, domains, func(emit func(string) bool) {
+		for _, domain := range domains {
+			for _, word := range words {
+				if !emit(word + "." + domain) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// run 创建一个独立的 runner 执行扫描，feed 通过 emit 投递待查询的域名
+func (s *Scanner) run(ctx context.Context, method options.OptionMethod, roots []string, feed func(emit func(string) bool)) (<-chan Result, error) {
+	results := make(chan Result, 100)
+	writer := &chanOutput{ctx: ctx, ch: results}
+
+	domainChan := make(chan string)
+	opt := &options.Options{
+		Rate:               s.opts.rate,
+		Domain:             domainChan,
+		Resolvers:          s.opts.resolvers,
+		Silent:             true,
+		TimeOut:            s.opts.timeout,
+		Retry:              s.opts.retry,
+		Method:             method,
+		Writer:             []outputter.Output{writer},
+		EtherInfo:          s.ether,
+		SpecialResolvers:   s.opts.specialDNS,
+		WildcardFilterMode: s.opts.wildcardMode,
+		Predict:            s.opts.predict,
+		PredictRules:       s.opts.predictRules,
+		QueryTypes:         s.opts.queryTypes,
+		RootDomains:        roots,
+		Depth:              s.opts.depth,
+		DepthWords:         s.opts.depthWords,
+		SourcePorts:        s.opts.sourcePorts,
+		Transport:          s.opts.transport,
+		Authoritative:      s.opts.authRate > 0 && len(roots) > 0,
+		AuthRate:           s.opts.authRate,
+	}
+	r, err := runner.New(opt)
+	if err != nil {
+		return nil, fmt.Errorf("创建扫描任务失败: %v", err)
+	}
+
+	go func() {
+		defer close(domainChan)
+		feed(func(domain string) bool {
+			select {
+			case domainChan <- domain:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+	go func() {
+		r.RunEnumeration(ctx)
+		// Close 会关闭输出器，从而关闭结果通道
+		r.Close()
+	}()
+	return results, nil
+}
+
+// chanOutput 将结果写入通道，ctx 取消后丢弃剩余结果
+type chanOutput struct {
+	ctx context.Context
+	ch  chan Result
+}
+
+func (c *chanOutput) WriteDomainResult(res result.Result) error {
+	select {
+	case c.ch <- res:
+		return nil
+	case <-c.ctx.Done():
+		return c.ctx.Err()
+	}
+}
+
+func (c *chanOutput) Close() error {
+	close(c.ch)
+	return nil
+}
@@ -0,0 +1,145 @@
+package ksubdomain_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/ksubdomain"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestResolver 启动本机解析器，所有 example.com 的子域名都解析为 1.2.3.4
+func startTestResolver(t *testing.T) string {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		q := req.Question[0]
+		if q.Qtype == dns.TypeA && strings.HasSuffix(q.Name, ".example.com.") {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: q.Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP("1.2.3.4"),
+			})
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestConcurrentScans(t *testing.T) {
+	scanner, err := ksubdomain.New(ksubdomain.NewOptions().
+		WithRate(1000).
+		WithResolvers(startTestResolver(t)).
+		WithTransport(options.TransportUDP).
+		WithWildcardFilter("none").
+		WithTimeout(3).
+		WithRetry(1))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// 同一个 Scanner 同时运行多次扫描，每次扫描只收到自己的结果
+	const scans, perScan = 4, 20
+	got := make([][]string, scans)
+	want := make([][]string, scans)
+	var wg sync.WaitGroup
+	for i := 0; i < scans; i++ {
+		for j := 0; j < perScan; j++ {
+			want[i] = append(want[i], fmt.Sprintf("s%d-%d.example.com", i, j))
+		}
+		sort.Strings(want[i])
+		results, err := scanner.Scan(ctx, want[i])
+		if !assert.NoError(t, err) {
+			return
+		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for res := range results {
+				got[i] = append(got[i], res.Subdomain)
+			}
+			sort.Strings(got[i])
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, want, got)
+}
+
+func TestSpecialResolvers(t *testing.T) {
+	newScanner := func(resolvers ...string) error {
+		_, err := ksubdomain.New(ksubdomain.NewOptions().
+			WithTransport(options.TransportUDP).
+			WithSpecialResolvers("example.com", resolvers...))
+		return err
+	}
+	assert.NoError(t, newScanner("1.1.1.1", "8.8.8.8:53", "[2001:db8::1]:5353"))
+	assert.Error(t, newScanner("dns.example.com"))
+	assert.Error(t, newScanner("1.1.1.1:0"))
+	assert.Error(t, newScanner("tls://1.1.1.1"))
+	assert.Error(t, newScanner())
+}
+
+func TestLogger(t *testing.T) {
+	var logs safeBuffer
+	scanner, err := ksubdomain.New(ksubdomain.NewOptions().
+		WithRate(1000).
+		WithResolvers(startTestResolver(t)).
+		WithTransport(options.TransportUDP).
+		WithWildcardFilter("none").
+		WithTimeout(3).
+		WithRetry(1).
+		WithLogger(&logs))
+	if !assert.NoError(t, err) {
+		return
+	}
+	results, err := scanner.Scan(context.Background(), []string{"www.example.com"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for range results {
+	}
+	assert.Contains(t, logs.String(), "速率限制")
+
+	// 不指定时日志被丢弃
+	_, err = ksubdomain.New(ksubdomain.NewOptions().WithTransport(options.TransportUDP))
+	assert.NoError(t, err)
+	before := logs.String()
+	gologger.Infof("丢弃的日志\n")
+	assert.Equal(t, before, logs.String())
+}
+
+// safeBuffer 可并发写入的日志缓冲
+type safeBuffer struct {
+	mu sync.Mutex
+	sb strings.Builder
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.sb.String()
+}
@@ -0,0 +1,220 @@
+package ksubdomain
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner"
+	"github.com/boy-hack/ksubdomain/v2/pkg/utils"
+	"github.com/google/gopacket/layers"
+)
+
+// 默认参数，与命令行保持一致
+const (
+	DefaultBandwidth = "2M"
+	DefaultTimeout   = 6
+	DefaultRetry     = 3
+)
+
+// Options 扫描参数，使用 NewOptions 创建后链式设置；
+// 设置过程中的错误会保留到 New 时一并返回
+type Options struct {
+	rate         int64
+	resolvers    []string
+	timeout      int
+	retry        int
+	queryTypes   []layers.DNSType
+	wildcardMode string
+	ether        *device.EtherTable
+	predict      bool
+	predictRules *predict.Rules
+	depth        int
+	depthWords   []string
+	specialDNS   map[string][]string
+	sourcePorts  int
+	transport    string
+	authRate     int       // 大于0时开启权威直连模式
+	logOutput    io.Writer // 扫描日志的输出，为nil时丢弃
+	err          error
+}
+
+// NewOptions 返回默认参数：2M带宽、内置解析器、超时6秒、重试3次、只查询A记录
+func NewOptions() *Options {
+	rate, _ := options.Band2Rate(DefaultBandwidth)
+	return &Options{
+		rate:         rate,
+		resolvers:    options.DefaultResolvers(),
+		timeout:      DefaultTimeout,
+		retry:        DefaultRetry,
+		wildcardMode: utils.DefaultWildcardFilterMode,
+		depth:        1,
+	}
+}
+
+// setErr 记录第一个设置错误
+func (o *Options) setErr(err error) *Options {
+	if o.err == nil {
+		o.err = err
+	}
+	return o
+}
+
+// WithBandwidth 按带宽设置发包速率，如 5M、500k
+func (o *Options) WithBandwidth(bandwidth string) *Options {
+	rate, err := options.Band2Rate(bandwidth)
+	if err != nil {
+		return o.setErr(err)
+	}
+	o.rate = rate
+	return o
+}
+
+// WithRate 直接设置每秒发包数
+func (o *Options) WithRate(pps int64) *Options {
+	if pps <= 0 {
+		return o.setErr(fmt.Errorf("发包速率必须大于0: %d", pps))
+	}
+	o.rate = pps
+	return o
+}
+
+// WithResolvers 设置DNS解析器，支持 ip 或 ip:port；
+// tls://host[:port] 和 https://host/dns-query 为加密解析器，查询通过 DoT/DoH 发送，不能与明文解析器混用
+func (o *Options) WithResolvers(resolvers ...string) *Options {
+	var list []string
+	for _, resolver := range resolvers {
+		normalized, err := options.ParseResolver(resolver)
+		if err != nil {
+			return o.setErr(err)
+		}
+		list = append(list, normalized)
+	}
+	if len(list) == 0 {
+		return o.setErr(fmt.Errorf("解析器列表不能为空"))
+	}
+	o.resolvers = list
+	return o
+}
+
+// WithSpecialResolvers 为特定域名后缀指定解析器，支持 ip 或 ip:port
+func (o *Options) WithSpecialResolvers(suffix string, resolvers ...string) *Options {
+	if suffix == "" {
+		return o.setErr(fmt.Errorf("域名后缀不能为空"))
+	}
+	var list []string
+	for _, resolver := range resolvers {
+		if options.IsEncryptedResolver(resolver) {
+			return o.setErr(fmt.Errorf("特定域名的解析器不支持加密解析器: %s", resolver))
+		}
+		normalized, err := options.ParseResolver(resolver)
+		if err != nil {
+			return o.setErr(err)
+		}
+		list = append(list, normalized)
+	}
+	if len(list) == 0 {
+		return o.setErr(fmt.Errorf("%s 的解析器列表不能为空", suffix))
+	}
+	if o.specialDNS == nil {
+		o.specialDNS = make(map[string][]string)
+	}
+	o.specialDNS[suffix] = append(o.specialDNS[suffix], list...)
+	return o
+}
+
+// WithTimeout 设置单次查询的超时时间(秒)
+func (o *Options) WithTimeout(seconds int) *Options {
+	if seconds <= 0 {
+		return o.setErr(fmt.Errorf("超时时间必须大于0: %d", seconds))
+	}
+	o.timeout = seconds
+	return o
+}
+
+// WithRetry 设置最大重试次数，-1 表示一直重试
+func (o *Options) WithRetry(retry int) *Options {
+	o.retry = retry
+	return o
+}
+
+// WithQueryTypes 设置查询的记录类型，如 a、aaaa、cname
+func (o *Options) WithQueryTypes(types ...string) *Options {
+	queryTypes, err := options.ParseQueryTypes(types)
+	if err != nil {
+		return o.setErr(err)
+	}
+	o.queryTypes = queryTypes
+	return o
+}
+
+// WithWildcardFilter 设置泛解析过滤模式: none, zone-probe；
+// 结果通道逐条输出，不支持需要在扫描结束后统计全部结果的 basic、advanced、combined
+func (o *Options) WithWildcardFilter(mode string) *Options {
+	if err := utils.CheckLiveWildcardMode(mode); err != nil {
+		return o.setErr(err)
+	}
+	o.wildcardMode = mode
+	return o
+}
+
+// WithDevice 指定发包网卡，不指定时在 New 中自动识别
+func (o *Options) WithDevice(ether *device.EtherTable) *Options {
+	o.ether = ether
+	return o
+}
+
+// WithPredict 开启预测模式，rules 为空时使用内置规则
+func (o *Options) WithPredict(rules *predict.Rules) *Options {
+	o.predict = true
+	o.predictRules = rules
+	return o
+}
+
+// WithDepth 设置 Enum 的递归枚举深度，words 为空时使用内置 subnext 字典
+func (o *Options) WithDepth(depth int, words []string) *Options {
+	if depth < 1 {
+		return o.setErr(fmt.Errorf("递归深度必须大于0: %d", depth))
+	}
+	o.depth = depth
+	o.depthWords = words
+	return o
+}
+
+// WithSourcePorts 设置发送查询使用的源端口数量，查询轮流使用这些端口
+func (o *Options) WithSourcePorts(n int) *Options {
+	if n < 1 || n > 256 {
+		return o.setErr(fmt.Errorf("源端口数量需要在 1-256 之间: %d", n))
+	}
+	o.sourcePorts = n
+	return o
+}
+
+// WithAuthoritative 开启权威直连模式，只对 Enum 生效：发现根域名及子区域委派的NS，以RD=0直接查询权威服务器，
+// rate 为每个权威服务器每秒最多发送的查询数，小于等于0时使用默认值
+func (o *Options) WithAuthoritative(rate int) *Options {
+	if rate <= 0 {
+		rate = runner.DefaultAuthRate
+	}
+	o.authRate = rate
+	return o
+}
+
+// WithLogger 将扫描日志写入 w，默认丢弃日志，不输出到宿主进程的终端；
+// 日志输出由进程内所有 Scanner 共享，以最后创建的 Scanner 为准
+func (o *Options) WithLogger(w io.Writer) *Options {
+	o.logOutput = w
+	return o
+}
+
+// WithTransport 设置发包方式: pcap 原始发包(默认，需要 root 或 CAP_NET_RAW 权限)，
+// udp 使用普通socket，不需要特殊权限，也不需要识别网卡
+func (o *Options) WithTransport(mode string) *Options {
+	if mode != options.TransportPcap && mode != options.TransportUDP {
+		return o.setErr(fmt.Errorf("发包方式只能是 pcap 或 udp: %s", mode))
+	}
+	o.transport = mode
+	return o
+}
@@ -0,0 +1,432 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/ns"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+	"github.com/google/gopacket/layers"
+)
+
+// DefaultAuthRate 权威直连模式下每个权威服务器默认每秒最多发送的查询数
+const DefaultAuthRate = 100
+
+// authZone 一个区域及其权威服务器
+type authZone struct {
+	name    string
+	servers []string        // 权威服务器地址，ready 关闭后只读
+	ready   chan struct{}   // NS地址解析完成后关闭
+	members map[string]bool // servers 的集合
+}
+
+// usable 区域的NS地址已解析完成且至少有一个可用的权威服务器
+func (z *authZone) usable() bool {
+	select {
+	case <-z.ready:
+		return len(z.servers) > 0
+	default:
+		return false
+	}
+}
+
+// authLimit 单个权威服务器的发送配额，按固定间隔分配发送时刻
+type authLimit struct {
+	mu       sync.Mutex
+	next     time.Time
+	interval time.Duration
+}
+
+// wait 预约下一个发送时刻并等待，ctx 取消时返回false
+func (l *authLimit) wait(ctx context.Context) bool {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	at := l.next
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	d := time.Until(at)
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// authority 权威直连模式：记录每个区域(包括扫描中由引荐发现的子区域委派)的权威服务器，
+// 查询以RD=0直接发给域名所在最深区域的权威服务器；每个权威服务器有独立的发送队列和配额，
+// 一个服务器限速不会拖慢发往其他服务器的查询
+type authority struct {
+	mu        sync.RWMutex
+	zones     map[string]*authZone
+	servers   map[string]bool // 所有区域的权威服务器
+	limits    map[string]*authLimit
+	queues    map[string]chan func() // 按权威服务器索引的发送队列
+	wg        sync.WaitGroup         // 发送队列的协程
+	rate      int
+	port      uint16             // 权威服务器端口
+	bootstrap []string           // 查询NS和解析NS主机名使用的递归解析器
+	ether     *device.EtherTable // 为nil时不按网卡支持的地址族过滤权威服务器
+}
+
+func newAuthority(bootstrap []string, rate int, port uint16, ether *device.EtherTable) *authority {
+	if rate <= 0 {
+		rate = DefaultAuthRate
+	}
+	return &authority{
+		zones:     make(map[string]*authZone),
+		servers:   make(map[string]bool),
+		limits:    make(map[string]*authLimit),
+		queues:    make(map[string]chan func()),
+		rate:      rate,
+		port:      port,
+		bootstrap: bootstrap,
+		ether:     ether,
+	}
+}
+
+// initAuthority 开启权威直连模式，扫描前查询每个根域名的权威服务器，查询失败的根域名仍使用递归解析器
+func (r *Runner) initAuthority() error {
+	opt := r.options
+	if len(opt.RootDomains) == 0 {
+		return fmt.Errorf("权威直连模式需要指定根域名")
+	}
+	if opt.Transport == options.TransportEncrypted {
+		return fmt.Errorf("权威直连模式不能使用加密解析器")
+	}
+	port := opt.AuthPort
+	if port == 0 {
+		port = options.DefaultDNSPort
+	}
+	r.authority = newAuthority(opt.Resolvers, opt.AuthRate, port, opt.EtherInfo)
+	gologger.Infof("权威直连模式: 每个权威服务器限速 %d pps\n", r.authority.rate)
+	for _, root := range opt.RootDomains {
+		if err := r.authority.discover(root); err != nil {
+			gologger.Warningf("查询 %s 的权威服务器失败，该域名使用递归解析器: %v\n", root, err)
+		}
+	}
+	return nil
+}
+
+// discover 通过递归解析器查询区域的NS记录并解析NS地址，用于扫描开始前登记根域名
+func (a *authority) discover(zone string) error {
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	hosts, err := ns.NameServers(zone, a.bootstrapServer())
+	if err != nil {
+		return err
+	}
+	z, created := a.zone(zone)
+	if created {
+		a.resolve(z, hosts, nil)
+	}
+	<-z.ready
+	if len(z.servers) == 0 {
+		return fmt.Errorf("区域 %s 没有可用的权威服务器", zone)
+	}
+	return nil
+}
+
+// delegate 登记引荐中的子区域委派，首次登记时在后台解析NS地址；返回的区域在 ready 关闭后可用
+func (a *authority) delegate(zone string, hosts []string, glue map[string][]net.IP) *authZone {
+	z, created := a.zone(zone)
+	if created {
+		go a.resolve(z, hosts, glue)
+	}
+	return z
+}
+
+// zone 返回区域，不存在时创建一个等待解析NS地址的区域
+func (a *authority) zone(name string) (*authZone, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if z, ok := a.zones[name]; ok {
+		return z, false
+	}
+	z := &authZone{name: name, ready: make(chan struct{})}
+	a.zones[name] = z
+	return z, true
+}
+
+// resolve 优先使用胶水记录，没有胶水记录的NS主机名通过递归解析器解析，完成后关闭 ready
+func (a *authority) resolve(z *authZone, hosts []string, glue map[string][]net.IP) {
+	members := make(map[string]bool)
+	var servers []string
+	for _, host := range hosts {
+		ips := glue[host]
+		if len(ips) == 0 {
+			var err error
+			if ips, err = ns.LookupHost(host, a.bootstrapServer()); err != nil {
+				gologger.Debugf("解析权威服务器 %s 失败: %s\n", host, err.Error())
+				continue
+			}
+		}
+		for _, ip := range ips {
+			if !a.reachable(ip) {
+				continue
+			}
+			server := options.JoinResolver(ip, a.port)
+			if !members[server] {
+				members[server] = true
+				servers = append(servers, server)
+			}
+		}
+	}
+
+	a.mu.Lock()
+	z.servers, z.members = servers, members
+	for server := range members {
+		a.servers[server] = true
+	}
+	a.mu.Unlock()
+	close(z.ready)
+
+	if len(servers) == 0 {
+		gologger.Warningf("区域 %s 没有可用的权威服务器\n", z.name)
+		return
+	}
+	gologger.Infof("区域 %s 的权威服务器: %s\n", z.name, strings.Join(servers, ","))
+}
+
+// reachable 网卡是否支持该地址族
+func (a *authority) reachable(ip net.IP) bool {
+	if a.ether == nil {
+		return true
+	}
+	if ip.To4() != nil {
+		return a.ether.SupportsIPv4()
+	}
+	return a.ether.SupportsIPv6()
+}
+
+func (a *authority) bootstrapServer() string {
+	return a.bootstrap[rand.Intn(len(a.bootstrap))]
+}
+
+// lookup 返回域名所在的最深的可用区域，域名不在任何已知区域内时返回nil
+func (a *authority) lookup(domain string) *authZone {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	name := strings.ToLower(domain)
+	for {
+		if z, ok := a.zones[name]; ok && z.usable() {
+			return z
+		}
+		i := strings.IndexByte(name, '.')
+		if i < 0 {
+			return nil
+		}
+		name = name[i+1:]
+	}
+}
+
+// server 为域名随机选择一个权威服务器，没有可用区域时返回空字符串
+func (a *authority) server(domain string) string {
+	z := a.lookup(domain)
+	if z == nil {
+		return ""
+	}
+	return z.servers[rand.Intn(len(z.servers))]
+}
+
+// isAuthoritative 地址是否为某个区域的权威服务器
+func (a *authority) isAuthoritative(server string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.servers[server]
+}
+
+// limit 返回权威服务器的发送配额，UDP发送队列和TCP重查共用同一配额
+func (a *authority) limit(server string) *authLimit {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	l, ok := a.limits[server]
+	if !ok {
+		l = &authLimit{interval: time.Second / time.Duration(a.rate)}
+		a.limits[server] = l
+	}
+	return l
+}
+
+// pick 权威服务器的发送队列已满时，换同一区域中队列未满的服务器
+func (a *authority) pick(domain string, server string) string {
+	if !a.full(server) {
+		return server
+	}
+	if z := a.lookup(domain); z != nil {
+		for _, other := range z.servers {
+			if !a.full(other) {
+				return other
+			}
+		}
+	}
+	return server
+}
+
+func (a *authority) full(server string) bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	q, ok := a.queues[server]
+	return ok && len(q) == cap(q)
+}
+
+// enqueue 把发送放入权威服务器的发送队列，首次使用时启动该服务器的发送协程；
+// 队列最多积压一秒的配额，只有区域内所有服务器的队列都满时才等待，ctx 取消时放弃发送
+func (a *authority) enqueue(ctx context.Context, server string, send func()) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	a.mu.Lock()
+	q, ok := a.queues[server]
+	if !ok {
+		q = make(chan func(), a.rate)
+		a.queues[server] = q
+		a.wg.Add(1)
+		go a.drain(ctx, server, q)
+	}
+	a.mu.Unlock()
+	select {
+	case q <- send:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// drain 按配额发送队列中的查询，ctx 取消后丢弃剩余查询
+func (a *authority) drain(ctx context.Context, server string, q chan func()) {
+	defer a.wg.Done()
+	l := a.limit(server)
+	for {
+		select {
+		case send := <-q:
+			if !l.wait(ctx) {
+				return
+			}
+			send()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// referral 从没有应答的非权威响应中提取子区域委派：区域名、NS主机名和胶水记录；
+// 只接受子区域内(in-bailiwick)NS主机名的胶水记录，其余NS主机名通过递归解析器解析，避免引荐指向任意地址
+func referral(dns *layers.DNS, qname string) (string, []string, map[string][]net.IP) {
+	if dns.ResponseCode != layers.DNSResponseCodeNoErr || dns.AA || len(dns.Answers) > 0 {
+		return "", nil, nil
+	}
+	qname = strings.ToLower(qname)
+	var zone string
+	var hosts []string
+	for _, rr := range dns.Authorities {
+		if rr.Type != layers.DNSTypeNS {
+			continue
+		}
+		owner := strings.ToLower(string(rr.Name))
+		if owner != qname && !strings.HasSuffix(qname, "."+owner) {
+			continue
+		}
+		if zone == "" {
+			zone = owner
+		}
+		if owner == zone {
+			hosts = append(hosts, strings.ToLower(string(rr.NS)))
+		}
+	}
+	if zone == "" {
+		return "", nil, nil
+	}
+	inBailiwick := make(map[string]bool)
+	for _, host := range hosts {
+		if host == zone || strings.HasSuffix(host, "."+zone) {
+			inBailiwick[host] = true
+		}
+	}
+	glue := make(map[string][]net.IP)
+	for _, rr := range dns.Additionals {
+		if rr.Type != layers.DNSTypeA && rr.Type != layers.DNSTypeAAAA {
+			continue
+		}
+		name := strings.ToLower(string(rr.Name))
+		if inBailiwick[name] {
+			glue[name] = append(glue[name], rr.IP)
+		}
+	}
+	return zone, hosts, glue
+}
+
+// followReferral 登记子区域委派，子区域可用后把查询改发给子区域的权威服务器
+func (r *Runner) followReferral(ctx context.Context, key string, item statusdb.Item, zone string, hosts []string, glue map[string][]net.IP) {
+	if cur := r.authority.lookup(item.Domain); cur != nil {
+		if zone == cur.name {
+			// 子区域在发送后才登记完成，直接改发；子区域的权威服务器引荐到自身为错误委派，换一个服务器重试
+			if !cur.members[item.Dns] {
+				r.followQuery(ctx, key)
+			} else {
+				r.requeue(ctx, key)
+			}
+			return
+		}
+		// 只跟随指向更深区域的引荐，忽略向上或无关的引荐
+		if !strings.HasSuffix(zone, "."+cur.name) {
+			r.requeue(ctx, key)
+			return
+		}
+	}
+
+	z := r.authority.delegate(zone, hosts, glue)
+	follow := func() {
+		if !z.usable() {
+			r.statusDB.Del(key)
+			atomic.AddUint64(&r.failedCount, 1)
+			return
+		}
+		r.followQuery(ctx, key)
+	}
+	select {
+	case <-z.ready:
+		follow()
+	default:
+		go func() {
+			select {
+			case <-z.ready:
+				follow()
+			case <-ctx.Done():
+			}
+		}()
+	}
+}
+
+// followQuery 按引荐立即重新发送查询，跟随引荐不计入重试次数
+func (r *Runner) followQuery(ctx context.Context, key string) {
+	v, ok := r.statusDB.Get(key)
+	if !ok {
+		return
+	}
+	v.Retry--
+	r.statusDB.Set(key, v)
+	select {
+	case r.retryChan <- v:
+	case <-ctx.Done():
+	default:
+		// 重试通道已满，交给超时重试处理
+	}
+}
@@ -0,0 +1,305 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/google/gopacket/layers"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveDNS 在 pc 上启动一个DNS服务
+func serveDNS(t *testing.T, pc net.PacketConn, handler dns.HandlerFunc) {
+	server := &dns.Server{PacketConn: pc, Handler: handler}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+}
+
+func testRR(t *testing.T, s string) dns.RR {
+	rr, err := dns.NewRR(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rr
+}
+
+// startTestAuthorities 启动 example.com 的权威服务器(127.0.0.1)和子区域的权威服务器(127.0.0.2)，两者使用同一端口；
+// sub.example.com 的委派带胶水记录，off.example.com 的NS需要通过递归解析器解析。
+// 返回端口和收到RD=1查询的计数
+func startTestAuthorities(t *testing.T) (uint16, *int32) {
+	parent, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	port := parent.LocalAddr().(*net.UDPAddr).Port
+	child, err := net.ListenPacket("udp4", net.JoinHostPort("127.0.0.2", strconv.Itoa(port)))
+	if err != nil {
+		parent.Close()
+		t.Skipf("无法监听 127.0.0.2: %v", err)
+	}
+
+	var recursive int32
+	answer := func(w dns.ResponseWriter, req *dns.Msg, records map[string]string, referrals map[string][]string) {
+		if req.RecursionDesired {
+			atomic.AddInt32(&recursive, 1)
+		}
+		m := new(dns.Msg)
+		m.SetReply(req)
+		name := req.Question[0].Name
+		for zone, rrs := range referrals {
+			if strings.HasSuffix(name, "."+zone) {
+				for _, rr := range rrs {
+					if strings.Contains(rr, " NS ") {
+						m.Ns = append(m.Ns, testRR(t, rr))
+					} else {
+						m.Extra = append(m.Extra, testRR(t, rr))
+					}
+				}
+				_ = w.WriteMsg(m)
+				return
+			}
+		}
+		m.Authoritative = true
+		if rr, ok := records[name]; ok {
+			m.Answer = append(m.Answer, testRR(t, rr))
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	}
+	serveDNS(t, parent, func(w dns.ResponseWriter, req *dns.Msg) {
+		answer(w, req, map[string]string{
+			"www.example.com.": "www.example.com. 60 IN A 1.1.1.1",
+		}, map[string][]string{
+			"sub.example.com.": {"sub.example.com. 60 IN NS ns.sub.example.com.", "ns.sub.example.com. 60 IN A 127.0.0.2"},
+			"off.example.com.": {"off.example.com. 60 IN NS ns.off.test."},
+		})
+	})
+	serveDNS(t, child, func(w dns.ResponseWriter, req *dns.Msg) {
+		answer(w, req, map[string]string{
+			"a.sub.example.com.": "a.sub.example.com. 60 IN A 2.2.2.2",
+			"a.off.example.com.": "a.off.example.com. 60 IN A 3.3.3.3",
+		}, nil)
+	})
+	return uint16(port), &recursive
+}
+
+// startTestBootstrap 启动递归解析器，只用于查询NS记录和解析NS主机名
+func startTestBootstrap(t *testing.T) string {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	records := map[string]string{
+		"example.com./NS":    "example.com. 60 IN NS ns1.example.com.",
+		"ns1.example.com./A": "ns1.example.com. 60 IN A 127.0.0.1",
+		"ns.off.test./A":     "ns.off.test. 60 IN A 127.0.0.2",
+	}
+	serveDNS(t, pc, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		q := req.Question[0]
+		if rr, ok := records[q.Name+"/"+dns.TypeToString[q.Qtype]]; ok {
+			m.Answer = append(m.Answer, testRR(t, rr))
+		}
+		_ = w.WriteMsg(m)
+	})
+	return pc.LocalAddr().String()
+}
+
+func TestAuthorityLookup(t *testing.T) {
+	a := newAuthority([]string{"127.0.0.1"}, 0, 53, nil)
+	assert.Equal(t, DefaultAuthRate, a.rate)
+	parent, _ := a.zone("example.com")
+	a.resolve(parent, []string{"ns1"}, map[string][]net.IP{"ns1": {net.ParseIP("192.0.2.1")}})
+	child, _ := a.zone("sub.example.com")
+	a.resolve(child, []string{"ns2"}, map[string][]net.IP{"ns2": {net.ParseIP("192.0.2.2")}})
+
+	assert.Equal(t, "192.0.2.2", a.server("x.a.sub.example.com"))
+	assert.Equal(t, "192.0.2.1", a.server("www.example.com"))
+	assert.Equal(t, "", a.server("example.org"))
+	assert.True(t, a.isAuthoritative("192.0.2.1"))
+	assert.False(t, a.isAuthoritative("8.8.8.8"))
+}
+
+func TestReferralGlue(t *testing.T) {
+	a := func(name, ip string) layers.DNSResourceRecord {
+		return layers.DNSResourceRecord{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN, IP: net.ParseIP(ip)}
+	}
+	ns := func(zone, host string) layers.DNSResourceRecord {
+		return layers.DNSResourceRecord{Name: []byte(zone), Type: layers.DNSTypeNS, Class: layers.DNSClassIN, NS: []byte(host)}
+	}
+	msg := &layers.DNS{
+		QR:          true,
+		Authorities: []layers.DNSResourceRecord{ns("sub.example.com", "ns.sub.example.com"), ns("sub.example.com", "ns.other.test")},
+		Additionals: []layers.DNSResourceRecord{
+			a("ns.sub.example.com", "192.0.2.1"),
+			a("ns.other.test", "198.51.100.1"),
+			a("www.bank.test", "198.51.100.2"),
+		},
+	}
+
+	// 子区域外的NS主机名和无关名称的胶水记录被丢弃，需通过递归解析器解析
+	zone, hosts, glue := referral(msg, "a.sub.example.com")
+	assert.Equal(t, "sub.example.com", zone)
+	assert.Equal(t, []string{"ns.sub.example.com", "ns.other.test"}, hosts)
+	assert.Equal(t, map[string][]net.IP{"ns.sub.example.com": {net.ParseIP("192.0.2.1")}}, glue)
+}
+
+func TestAuthorityQueue(t *testing.T) {
+	a := newAuthority([]string{"127.0.0.1"}, 20, 53, nil)
+	z, _ := a.zone("example.com")
+	a.resolve(z, []string{"ns1", "ns2"}, map[string][]net.IP{
+		"ns1": {net.ParseIP("192.0.2.1")},
+		"ns2": {net.ParseIP("192.0.2.2")},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 每个服务器按配额发送，发送协程取走两个查询后队列仍积压满一秒的配额，此时换同一区域的其他服务器
+	sent := make(chan time.Time, 64)
+	for i := 0; i < a.rate+2; i++ {
+		assert.True(t, a.enqueue(ctx, "192.0.2.1", func() { sent <- time.Now() }))
+	}
+	assert.Equal(t, "192.0.2.2", a.pick("www.example.com", "192.0.2.1"))
+	assert.Equal(t, "192.0.2.2", a.pick("www.example.com", "192.0.2.2"))
+
+	first := <-sent
+	for i := 0; i < 4; i++ {
+		<-sent
+	}
+	assert.True(t, time.Since(first) >= 4*time.Second/time.Duration(a.rate)-10*time.Millisecond)
+
+	cancel()
+	a.wg.Wait()
+	assert.False(t, a.enqueue(ctx, "192.0.2.2", func() {}))
+}
+
+func TestRunnerAuthoritative(t *testing.T) {
+	for _, mode := range []string{"none", "zone-probe"} {
+		port, recursive := startTestAuthorities(t)
+		bootstrap := startTestBootstrap(t)
+		domains := make(chan string)
+		go func() {
+			for _, d := range []string{"www.example.com", "nx.example.com", "a.sub.example.com", "a.off.example.com"} {
+				domains <- d
+			}
+			close(domains)
+		}()
+		out := &collectOutput{}
+		opt := &options.Options{
+			Rate:               1000,
+			Domain:             domains,
+			Resolvers:          []string{bootstrap},
+			Silent:             true,
+			TimeOut:            3,
+			Retry:              1,
+			Method:             options.VerifyType,
+			Writer:             []outputter.Output{out},
+			WildcardFilterMode: mode,
+			Transport:          options.TransportUDP,
+			RootDomains:        []string{"example.com"},
+			Authoritative:      true,
+			AuthRate:           1000,
+			AuthPort:           port,
+		}
+		r, err := New(opt)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, 1000, r.authority.rate)
+		assert.True(t, r.authority.isAuthoritative(options.JoinResolver(net.ParseIP("127.0.0.1"), port)))
+
+		r.RunEnumeration(context.Background())
+		r.Close()
+
+		got := make(map[string]string)
+		for _, res := range out.results {
+			got[res.Subdomain] = res.Answers[0].Value
+		}
+		assert.Equal(t, map[string]string{
+			"www.example.com":   "1.1.1.1",
+			"a.sub.example.com": "2.2.2.2",
+			"a.off.example.com": "3.3.3.3",
+		}, got, mode)
+		// 泛解析探测同样以RD=0发给权威服务器
+		assert.Equal(t, int32(0), atomic.LoadInt32(recursive), mode)
+		assert.NotNil(t, r.authority.lookup("a.off.example.com"))
+		assert.Equal(t, "off.example.com", r.authority.lookup("a.off.example.com").name)
+	}
+}
+
+func TestRunnerAuthoritativeRate(t *testing.T) {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	var received []time.Time
+	serveDNS(t, pc, func(w dns.ResponseWriter, req *dns.Msg) {
+		mu.Lock()
+		received = append(received, time.Now())
+		mu.Unlock()
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+		m.Rcode = dns.RcodeNameError
+		_ = w.WriteMsg(m)
+	})
+	bootstrap := startTestBootstrap(t)
+
+	const rate, total = 20, 50
+	domains := make(chan string)
+	go func() {
+		for i := 0; i < total; i++ {
+			domains <- "n" + strconv.Itoa(i) + ".example.com"
+		}
+		close(domains)
+	}()
+	opt := &options.Options{
+		Rate:               1000,
+		Domain:             domains,
+		Resolvers:          []string{bootstrap},
+		Silent:             true,
+		TimeOut:            5,
+		Retry:              1,
+		Method:             options.VerifyType,
+		Writer:             []outputter.Output{&collectOutput{}},
+		WildcardFilterMode: "none",
+		Transport:          options.TransportUDP,
+		RootDomains:        []string{"example.com"},
+		Authoritative:      true,
+		AuthRate:           rate,
+		AuthPort:           uint16(pc.LocalAddr().(*net.UDPAddr).Port),
+	}
+	r, err := New(opt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	r.RunEnumeration(context.Background())
+	r.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !assert.Len(t, received, total) {
+		return
+	}
+	sort.Slice(received, func(i, j int) bool { return received[i].Before(received[j]) })
+	// 全局速率远高于权威服务器配额，任意一秒内权威服务器收到的查询数不超过配额
+	for i := range received {
+		n := sort.Search(len(received), func(j int) bool { return received[j].Sub(received[i]) >= time.Second })
+		assert.LessOrEqual(t, n-i, rate+1)
+	}
+	elapsed := received[total-1].Sub(received[0])
+	assert.True(t, elapsed >= time.Duration(total-1)*time.Second/rate-100*time.Millisecond, "发送过快: %s", elapsed)
+}
@@ -0,0 +1,213 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/google/gopacket/pcap"
+)
+
+// captures 进程内每块网卡共享一个抓包句柄，多个扫描同时运行时由它按端口分发响应
+var captures = struct {
+	sync.Mutex
+	m map[string]*captureMux
+}{m: make(map[string]*captureMux)}
+
+// captureMux 一块网卡上的共享抓包句柄，按目的端口把响应分发给订阅者
+type captureMux struct {
+	device  string
+	handle  *pcap.Handle
+	framing device.Framing // 抓包句柄的链路封装方式
+
+	mu   sync.RWMutex
+	subs map[uint16]*captureSub // 按监听端口索引
+}
+
+// captureSub 一个扫描的订阅，拥有一个或多个独立的监听端口，DNS ID 由订阅者自行校验
+type captureSub struct {
+	mux     *captureMux
+	ports   []uint16
+	conns   []*net.UDPConn // 占用监听端口，避免本机其他程序使用同一端口
+	C       chan dnsResponse
+	closed  chan struct{}
+	once    sync.Once
+	dropped uint64 // 响应通道已满而丢弃的响应数，由超时重试补发
+}
+
+// subscribeCapture 在网卡上订阅发往 ports 个空闲端口的DNS响应
+func subscribeCapture(deviceName string, ports int, buffer int) (*captureSub, error) {
+	if ports < 1 {
+		ports = 1
+	}
+	sub := &captureSub{
+		C:      make(chan dnsResponse, buffer),
+		closed: make(chan struct{}),
+	}
+	for i := 0; i < ports; i++ {
+		conn, err := net.ListenUDP("udp", &net.UDPAddr{})
+		if err != nil {
+			sub.closeConns()
+			return nil, fmt.Errorf("分配监听端口失败: %v", err)
+		}
+		sub.conns = append(sub.conns, conn)
+		sub.ports = append(sub.ports, uint16(conn.LocalAddr().(*net.UDPAddr).Port))
+	}
+
+	captures.Lock()
+	defer captures.Unlock()
+	mux, ok := captures.m[deviceName]
+	if !ok {
+		handle, err := openRecvHandle(deviceName)
+		if err != nil {
+			sub.closeConns()
+			return nil, err
+		}
+		framing, err := device.LinkFraming(handle.LinkType())
+		if err != nil {
+			handle.Close()
+			sub.closeConns()
+			return nil, err
+		}
+		mux = &captureMux{
+			device:  deviceName,
+			handle:  handle,
+			framing: framing,
+			subs:    make(map[uint16]*captureSub),
+		}
+	}
+	mux.mu.Lock()
+	for _, port := range sub.ports {
+		mux.subs[port] = sub
+	}
+	err := mux.updateFilter()
+	if err != nil {
+		for _, port := range sub.ports {
+			delete(mux.subs, port)
+		}
+	}
+	mux.mu.Unlock()
+	if err != nil {
+		sub.closeConns()
+		if !ok {
+			mux.handle.Close()
+		}
+		return nil, err
+	}
+	if !ok {
+		captures.m[deviceName] = mux
+		go mux.run()
+	}
+	sub.mux = mux
+	return sub, nil
+}
+
+// Close 取消订阅并释放端口，最后一个订阅者退出时关闭抓包句柄
+func (s *captureSub) Close() {
+	s.once.Do(func() {
+		close(s.closed)
+		s.closeConns()
+		if dropped := atomic.LoadUint64(&s.dropped); dropped > 0 {
+			gologger.Warningf("接收队列已满，丢弃了 %d 个响应，可以降低发包速率\n", dropped)
+		}
+
+		captures.Lock()
+		defer captures.Unlock()
+		mux := s.mux
+		mux.mu.Lock()
+		for _, port := range s.ports {
+			delete(mux.subs, port)
+		}
+		remaining := len(mux.subs)
+		if remaining > 0 {
+			_ = mux.updateFilter()
+		}
+		mux.mu.Unlock()
+		if remaining == 0 {
+			delete(captures.m, mux.device)
+			mux.handle.Close()
+		}
+	})
+}
+
+func (s *captureSub) closeConns() {
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// updateFilter 只捕获发往订阅端口的UDP包，调用时需持有 mu
+func (m *captureMux) updateFilter() error {
+	ports := make([]int, 0, len(m.subs))
+	for port := range m.subs {
+		ports = append(ports, int(port))
+	}
+	sort.Ints(ports)
+	conds := make([]string, len(ports))
+	for i, port := range ports {
+		conds[i] = fmt.Sprintf("dst port %d", port)
+	}
+	filter := fmt.Sprintf("udp and (%s)", strings.Join(conds, " or "))
+	if err := m.handle.SetBPFFilter(filter); err != nil {
+		return fmt.Errorf("设置BPF过滤器失败: %v", err)
+	}
+	return nil
+}
+
+// run 读取数据包并交给多个解码协程，句柄关闭后退出
+func (m *captureMux) run() {
+	packetChan := make(chan []byte, 10000)
+	var wg sync.WaitGroup
+	workers := runtime.NumCPU() * 2
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			dc := newDecodingContext(m.framing)
+			for data := range packetChan {
+				m.dispatch(dc, data)
+			}
+		}()
+	}
+
+	for {
+		data, _, err := m.handle.ReadPacketData()
+		if err != nil {
+			if errors.Is(err, pcap.NextErrorTimeoutExpired) {
+				continue
+			}
+			break
+		}
+		packetChan <- data
+	}
+	close(packetChan)
+	wg.Wait()
+}
+
+// dispatch 解码响应，按目的端口找到订阅者；订阅者的响应通道已满时丢弃响应并计数，
+// 避免一个处理缓慢的扫描阻塞同一网卡上的其他扫描
+func (m *captureMux) dispatch(dc *decodingContext, data []byte) {
+	resp, ok := dc.decode(data)
+	if !ok {
+		return
+	}
+	m.mu.RLock()
+	sub, ok := m.subs[resp.port]
+	m.mu.RUnlock()
+	if !ok {
+		return
+	}
+	select {
+	case sub.C <- resp:
+	case <-sub.closed:
+	default:
+		atomic.AddUint64(&sub.dropped, 1)
+	}
+}
@@ -0,0 +1,158 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/resolverpool"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+// buildResponse 构造一个发往 port 的DNS响应包
+func buildResponse(t *testing.T, port uint16, id uint16, name string) []byte {
+	eth := &layers.Ethernet{
+		SrcMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC:       net.HardwareAddr{0, 1, 2, 3, 4, 6},
+		EthernetType: layers.EthernetTypeIPv4,
+	}
+	ip := &layers.IPv4{
+		Version:  4,
+		TTL:      64,
+		Protocol: layers.IPProtocolUDP,
+		SrcIP:    net.ParseIP("8.8.8.8").To4(),
+		DstIP:    net.ParseIP("10.0.0.2").To4(),
+	}
+	udp := &layers.UDP{SrcPort: 53, DstPort: layers.UDPPort(port)}
+	_ = udp.SetNetworkLayerForChecksum(ip)
+	dns := &layers.DNS{
+		ID: id,
+		QR: true,
+		Questions: []layers.DNSQuestion{
+			{Name: []byte(name), Type: layers.DNSTypeA, Class: layers.DNSClassIN},
+		},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true},
+		eth, ip, udp, dns)
+	assert.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestCaptureDispatch(t *testing.T) {
+	newSub := func(ports ...uint16) *captureSub {
+		return &captureSub{ports: ports, C: make(chan dnsResponse, 4), closed: make(chan struct{})}
+	}
+	a, b := newSub(40001, 40004), newSub(40002)
+	m := &captureMux{subs: map[uint16]*captureSub{40001: a, 40004: a, 40002: b}}
+	dc := newDecodingContext(device.FramingEthernet)
+
+	m.dispatch(dc, buildResponse(t, 40001, 0x1111, "a.example.com"))
+	m.dispatch(dc, buildResponse(t, 40004, 0x1112, "a.example.com"))
+	m.dispatch(dc, buildResponse(t, 40002, 0x2222, "b.example.com"))
+	// 端口无人订阅的响应被丢弃
+	m.dispatch(dc, buildResponse(t, 40003, 0x1111, "a.example.com"))
+
+	assert.Len(t, a.C, 2)
+	assert.Len(t, b.C, 1)
+	resp := <-a.C
+	assert.Equal(t, "a.example.com", string(resp.dns.Questions[0].Name))
+	assert.Equal(t, "8.8.8.8", resp.resolver)
+	assert.Equal(t, uint16(40001), resp.port)
+	assert.Equal(t, uint16(0x1111), resp.dns.ID)
+	resp = <-b.C
+	assert.Equal(t, "b.example.com", string(resp.dns.Questions[0].Name))
+
+	// 订阅者的响应通道已满时不阻塞，其他订阅者照常收到响应
+	for i := 0; i < 6; i++ {
+		m.dispatch(dc, buildResponse(t, 40001, uint16(i), "a.example.com"))
+	}
+	m.dispatch(dc, buildResponse(t, 40002, 0x2223, "b.example.com"))
+	assert.Len(t, a.C, 4)
+	assert.Equal(t, uint64(3), a.dropped)
+	assert.Len(t, b.C, 1)
+	assert.Equal(t, uint64(0), b.dropped)
+}
+
+func TestDecodeResponse(t *testing.T) {
+	dc := newDecodingContext(device.FramingEthernet)
+	data := buildResponse(t, 40001, 0x1111, "a.example.com")
+	resp, ok := dc.decode(data)
+	assert.True(t, ok)
+	// 链路解析到UDP层为止，DNS层只从UDP负载解码一次
+	assert.NotContains(t, dc.decoded, layers.LayerTypeDNS)
+	// 响应不引用数据包的内存
+	for i := range data {
+		data[i] = 0
+	}
+	assert.Equal(t, "a.example.com", string(resp.dns.Questions[0].Name))
+	assert.Equal(t, "8.8.8.8", resp.resolver)
+}
+
+func TestHandleResponseValidation(t *testing.T) {
+	ctx := context.Background()
+	r := &Runner{
+		statusDB:     statusdb.CreateMemoryDB(),
+		resolverPool: resolverpool.New([]string{"8.8.8.8"}),
+		resultChan:   make(chan result.Result, 4),
+		transport:    &pcapTransport{capture: &captureSub{ports: []uint16{40001, 40002}}},
+	}
+	key := statusdb.Key("a.example.com", layers.DNSTypeA)
+	r.statusDB.Add(key, statusdb.Item{Domain: "a.example.com", QType: layers.DNSTypeA, Dns: "8.8.8.8", ID: 0x1234, Port: 40002})
+
+	response := func(port uint16, id uint16, name string) dnsResponse {
+		resp, ok := newDecodingContext(device.FramingEthernet).decode(buildResponse(t, port, id, name))
+		assert.True(t, ok)
+		resp.dns.ANCount = 1
+		return resp
+	}
+	// ID、源端口、问题或来源解析器不匹配的响应被丢弃
+	r.handleResponse(ctx, response(40002, 0x1235, "a.example.com"))
+	r.handleResponse(ctx, response(40001, 0x1234, "a.example.com"))
+	r.handleResponse(ctx, response(40002, 0x1234, "b.example.com"))
+	spoofed := response(40002, 0x1234, "a.example.com")
+	spoofed.resolver = "9.9.9.9"
+	r.handleResponse(ctx, spoofed)
+	assert.Equal(t, uint64(4), r.rejectedCount)
+	assert.Len(t, r.resultChan, 0)
+
+	r.handleResponse(ctx, response(40002, 0x1234, "a.example.com"))
+	assert.Equal(t, uint64(1), r.receiveCount)
+	assert.Len(t, r.resultChan, 1)
+
+	// 重复的响应不再被接受
+	r.handleResponse(ctx, response(40002, 0x1234, "a.example.com"))
+	assert.Equal(t, uint64(5), r.rejectedCount)
+
+	// 同时到达的多份相同响应只有一份产生结果；省略默认端口的解析器地址视为相同
+	<-r.resultChan
+	key = statusdb.Key("c.example.com", layers.DNSTypeA)
+	r.statusDB.Add(key, statusdb.Item{Domain: "c.example.com", QType: layers.DNSTypeA, Dns: "8.8.8.8:53", ID: 0x4321, Port: 40001})
+	dup := response(40001, 0x4321, "c.example.com")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.handleResponse(ctx, dup)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, r.resultChan, 1)
+	assert.Equal(t, uint64(12), r.rejectedCount)
+}
+
+func TestNextSourcePort(t *testing.T) {
+	r := &Runner{transport: &pcapTransport{capture: &captureSub{ports: []uint16{40001, 40002, 40003}}}}
+	seen := make(map[uint16]int)
+	for i := 0; i < 6; i++ {
+		seen[r.nextSourcePort()]++
+	}
+	assert.Equal(t, map[uint16]int{40001: 2, 40002: 2, 40003: 2}, seen)
+}
@@ -0,0 +1,173 @@
+package checkpoint
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+)
+
+// resultsSuffix 结果文件的后缀，结果按行追加写入，不随快照重写
+const resultsSuffix = ".results"
+
+// State 断点续扫需要持久化的状态
+type State struct {
+	Position  int64           `json:"position"`   // 已发送的源域名数量
+	Pending   []statusdb.Item `json:"pending"`    // 已发送但尚未收到响应的查询
+	Results   []result.Result `json:"-"`          // 已输出的结果，保存在结果文件中
+	UpdatedAt time.Time       `json:"updated_at"` // 最后保存时间
+}
+
+// Checkpoint 断点文件：快照文件只保存生产者位置和待响应的查询，每次保存原子替换；
+// 已输出的结果以 JSONL 追加到结果文件，保存快照前先刷新结果文件
+type Checkpoint struct {
+	filename string
+	state    State
+	mu       sync.Mutex
+	results  *os.File
+	w        *bufio.Writer
+}
+
+// Open 打开断点文件和结果文件，文件不存在时返回空状态
+func Open(filename string) (*Checkpoint, error) {
+	c := &Checkpoint{filename: filename}
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		err = json.Unmarshal(data, &c.state)
+	} else if os.IsNotExist(err) {
+		err = nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.results, err = os.OpenFile(filename+resultsSuffix, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.loadResults(); err != nil {
+		c.results.Close()
+		return nil, err
+	}
+	c.w = bufio.NewWriter(c.results)
+	return c, nil
+}
+
+// loadResults 读取结果文件，中断时写了一半的最后一行被截掉，之后从完整行的末尾继续追加
+func (c *Checkpoint) loadResults() error {
+	reader := bufio.NewReader(c.results)
+	var valid int64
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var res result.Result
+		if json.Unmarshal(bytes.TrimSpace(line), &res) != nil {
+			break
+		}
+		c.state.Results = append(c.state.Results, res)
+		valid += int64(len(line))
+	}
+	if err := c.results.Truncate(valid); err != nil {
+		return err
+	}
+	_, err := c.results.Seek(valid, io.SeekStart)
+	return err
+}
+
+// State 返回加载时的状态副本
+func (c *Checkpoint) State() State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	state := c.state
+	state.Pending = append([]statusdb.Item(nil), c.state.Pending...)
+	state.Results = append([]result.Result(nil), c.state.Results...)
+	return state
+}
+
+// AddResult 追加一条已输出的结果，写入缓冲后在保存快照时刷新
+func (c *Checkpoint) AddResult(res result.Result) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.w == nil {
+		return os.ErrClosed
+	}
+	data = append(data, '\n')
+	_, err = c.w.Write(data)
+	return err
+}
+
+// Save 先刷新结果文件，再保存生产者位置和待响应的查询；快照先写临时文件再重命名，避免中断时留下损坏的文件
+func (c *Checkpoint) Save(position int64, pending []statusdb.Item) error {
+	c.mu.Lock()
+	if c.w != nil {
+		if err := c.w.Flush(); err != nil {
+			c.mu.Unlock()
+			return err
+		}
+	}
+	c.state.Position = position
+	c.state.Pending = pending
+	c.state.UpdatedAt = time.Now()
+	data, err := json.Marshal(c.state)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.filename), filepath.Base(c.filename)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.filename)
+}
+
+// Close 刷新并关闭结果文件，可重复调用
+func (c *Checkpoint) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.w == nil {
+		return nil
+	}
+	err := c.w.Flush()
+	if cerr := c.results.Close(); err == nil {
+		err = cerr
+	}
+	c.w = nil
+	return err
+}
+
+// Remove 扫描完成后关闭并删除断点文件和结果文件
+func (c *Checkpoint) Remove() error {
+	_ = c.Close()
+	for _, name := range []string{c.filename, c.filename + resultsSuffix} {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
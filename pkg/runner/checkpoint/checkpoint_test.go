@@ -0,0 +1,76 @@
+package checkpoint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointSaveAndOpen(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "resume.json")
+
+	c, err := Open(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), c.State().Position)
+
+	assert.NoError(t, c.AddResult(result.Result{
+		Subdomain: "www.example.com",
+		Answers:   []result.Record{{Type: "A", Value: "1.1.1.1"}},
+	}))
+	pending := []statusdb.Item{{Domain: "mail.example.com", QType: layers.DNSTypeAAAA, Dns: "8.8.8.8", Retry: 1}}
+	assert.NoError(t, c.Save(42, pending))
+	assert.NoError(t, c.Close())
+
+	// 快照只保存位置和待响应查询，结果按行追加到结果文件
+	snapshot, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(snapshot), "www.example.com")
+
+	c2, err := Open(filename)
+	assert.NoError(t, err)
+	state := c2.State()
+	assert.Equal(t, int64(42), state.Position)
+	assert.Equal(t, pending[0].Domain, state.Pending[0].Domain)
+	assert.Equal(t, layers.DNSTypeAAAA, state.Pending[0].QType)
+	assert.Equal(t, "1.1.1.1", state.Results[0].Answers[0].Value)
+
+	assert.NoError(t, c2.Remove())
+	assert.NoError(t, c2.Remove())
+	_, err = os.Stat(filename + resultsSuffix)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCheckpointTornResult(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "resume.json")
+	c, err := Open(filename)
+	assert.NoError(t, err)
+	assert.NoError(t, c.AddResult(result.Result{Subdomain: "a.example.com"}))
+	assert.NoError(t, c.Close())
+
+	// 中断时写了一半的最后一行被丢弃，之后的结果从完整行之后追加
+	f, err := os.OpenFile(filename+resultsSuffix, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = f.WriteString(`{"subdomain":"b.exa`)
+	assert.NoError(t, err)
+	f.Close()
+
+	c, err = Open(filename)
+	assert.NoError(t, err)
+	assert.Len(t, c.State().Results, 1)
+	assert.NoError(t, c.AddResult(result.Result{Subdomain: "c.example.com"}))
+	assert.NoError(t, c.Close())
+
+	c, err = Open(filename)
+	assert.NoError(t, err)
+	results := c.State().Results
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, "a.example.com", results[0].Subdomain)
+		assert.Equal(t, "c.example.com", results[1].Subdomain)
+	}
+	assert.NoError(t, c.Close())
+}
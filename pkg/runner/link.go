@@ -0,0 +1,97 @@
+package runner
+
+import (
+	"encoding/binary"
+	"net"
+	"syscall"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// linkHeader 按链路封装方式创建发往IPv4或IPv6解析器 dstIP 的链路头
+// 原始IP链路返回nil；以太网缺少下一跳MAC时 ok 为false。neighbors 不为nil时IPv6下一跳由它选择，否则使用 DstMac6
+func linkHeader(framing device.Framing, ether *device.EtherTable, dstIP net.IP, neighbors *device.Neighbors) (header gopacket.SerializableLayer, ok bool) {
+	ipv6 := dstIP.To4() == nil
+	family, ethType := uint32(syscall.AF_INET), layers.EthernetTypeIPv4
+	if ipv6 {
+		family, ethType = uint32(syscall.AF_INET6), layers.EthernetTypeIPv6
+	}
+	switch framing {
+	case device.FramingEthernet:
+		dstMac := ether.DstMac.HardwareAddr()
+		if ipv6 {
+			dstMac = ether.DstMac6.HardwareAddr()
+			if neighbors != nil {
+				dstMac, _ = neighbors.NextHop6(dstIP)
+			}
+		}
+		if len(dstMac) == 0 {
+			return nil, false
+		}
+		return &layers.Ethernet{
+			SrcMAC:       ether.SrcMac.HardwareAddr(),
+			DstMAC:       dstMac,
+			EthernetType: ethType,
+		}, true
+	case device.FramingNull:
+		return &nullHeader{family: family, order: binary.NativeEndian}, true
+	case device.FramingLoop:
+		return &nullHeader{family: family, order: binary.BigEndian}, true
+	case device.FramingSLL:
+		return &sllHeader{protocol: ethType, addr: ether.SrcMac.HardwareAddr()}, true
+	}
+	return nil, true
+}
+
+// nullHeader BSD loopback 链路头，DLT_NULL 为主机字节序，DLT_LOOP 为网络字节序
+// gopacket 的 Loopback 层固定按小端序列化，这里自行实现
+type nullHeader struct {
+	family uint32
+	order  binary.ByteOrder
+}
+
+func (h *nullHeader) LayerType() gopacket.LayerType { return layers.LayerTypeLoopback }
+
+func (h *nullHeader) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(4)
+	if err != nil {
+		return err
+	}
+	h.order.PutUint32(bytes, h.family)
+	return nil
+}
+
+// sllHeader Linux cooked capture 链路头，gopacket 的 LinuxSLL 层不支持序列化
+type sllHeader struct {
+	protocol layers.EthernetType
+	addr     net.HardwareAddr
+}
+
+func (h *sllHeader) LayerType() gopacket.LayerType { return layers.LayerTypeLinuxSLL }
+
+func (h *sllHeader) SerializeTo(b gopacket.SerializeBuffer, opts gopacket.SerializeOptions) error {
+	bytes, err := b.PrependBytes(16)
+	if err != nil {
+		return err
+	}
+	for i := range bytes {
+		bytes[i] = 0
+	}
+	// ARPHRD_ETHER 或没有链路地址的 ARPHRD_NONE
+	addrType := uint16(0xfffe)
+	if len(h.addr) == 6 {
+		addrType = 1
+	}
+	addrLen := len(h.addr)
+	if addrLen > 8 {
+		addrLen = 8
+	}
+	binary.BigEndian.PutUint16(bytes[0:2], uint16(layers.LinuxSLLPacketTypeOutgoing))
+	binary.BigEndian.PutUint16(bytes[2:4], addrType)
+	binary.BigEndian.PutUint16(bytes[4:6], uint16(addrLen))
+	copy(bytes[6:14], h.addr[:addrLen])
+	binary.BigEndian.PutUint16(bytes[14:16], uint16(h.protocol))
+	return nil
+}
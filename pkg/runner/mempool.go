@@ -8,7 +8,7 @@ import (
 )
 
 // MemoryPool 实现内存对象池
-// 用于复用频繁分配的对象，减少GC压力
+// 用于复用频繁分配的对象，减少GC压力，每个发送器持有一个
 type MemoryPool struct {
 	dnsPool      sync.Pool
 	bufPool      sync.Pool
@@ -16,9 +16,6 @@ type MemoryPool struct {
 	answerPool   sync.Pool
 }
 
-// 全局内存池实例
-var GlobalMemPool = NewMemoryPool()
-
 // NewMemoryPool 创建一个新的内存池
 func NewMemoryPool() *MemoryPool {
 	return &MemoryPool{
@@ -8,3 +8,8 @@ type Output interface {
 	WriteDomainResult(domain result.Result) error
 	Close() error
 }
+
+// SummaryOutput 可选接口，扫描结束、Close之前接收统计信息
+type SummaryOutput interface {
+	WriteSummary(summary result.Summary) error
+}
@@ -2,86 +2,115 @@ package output
 
 import (
 	"encoding/csv"
-	"os"
+	"fmt"
+	"io"
+	"strings"
+	"time"
 
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
-	"github.com/boy-hack/ksubdomain/v2/pkg/utils"
 )
 
+// CsvOutput csv格式输出，扫描过程中实时写入 <文件名>.live，结束时过滤后原子写入最终文件
 type CsvOutput struct {
-	domains        []result.Result
+	spool          *spool
 	filename       string
 	wildFilterMode string
 }
 
-func NewCsvOutput(filename string, wildFilterMode string) *CsvOutput {
+func NewCsvOutput(filename string, wildFilterMode string) (*CsvOutput, error) {
+	s, err := newSpool(filename+LiveSuffix, nil)
+	if err != nil {
+		return nil, err
+	}
 	f := new(CsvOutput)
-	f.domains = make([]result.Result, 0)
+	f.spool = s
 	f.filename = filename
 	f.wildFilterMode = wildFilterMode
-	return f
+	return f, nil
 }
 
 func (f *CsvOutput) WriteDomainResult(domain result.Result) error {
-	f.domains = append(f.domains, domain)
-	return nil
+	return f.spool.Add(domain)
 }
 
 func (f *CsvOutput) Close() error {
 	gologger.Infof("写入csv文件:%s\n", f.filename)
-
-	// 检查结果数量
-	if len(f.domains) == 0 {
-		gologger.Infof("没有发现子域名结果，CSV文件将为空\n")
-		return nil
-	}
-
-	results := utils.WildFilterOutputResult(f.wildFilterMode, f.domains)
-	gologger.Infof("过滤后结果数量: %d\n", len(results))
-
-	// 检查过滤后结果
-	if len(results) == 0 {
-		gologger.Infof("经过通配符过滤后没有有效结果，CSV文件将为空\n")
-		return nil
-	}
-
-	// 创建CSV文件
-	file, err := os.Create(f.filename)
+	n, err := f.spool.Finalize(f.filename, f.wildFilterMode, &csvArtifact{})
 	if err != nil {
-		gologger.Errorf("创建CSV文件失败: %v", err)
+		gologger.Errorf("写入CSV文件失败: %v", err)
 		return err
 	}
-	defer file.Close()
+	gologger.Infof("CSV文件写入成功，共写入 %d 条记录", n)
+	return nil
+}
 
-	// 创建CSV写入器
-	writer := csv.NewWriter(file)
+// csvArtifact 写入CSV头部和数据行
+type csvArtifact struct {
+	writer *csv.Writer
+}
 
+func (a *csvArtifact) Begin(w io.Writer) error {
+	a.writer = csv.NewWriter(w)
 	// 写入CSV头部
-	err = writer.Write([]string{"Subdomain", "Answers"})
-	if err != nil {
-		gologger.Errorf("写入CSV头部失败: %v", err)
-		return err
+	return a.writer.Write([]string{"Subdomain", "Type", "RCode", "Timestamp", "Answers", "MX", "SOA", "SRV", "CAA"})
+}
+
+func (a *csvArtifact) Write(w io.Writer, result result.Result) error {
+	// 将Answers数组转换为单个字符串，用分号分隔
+	answersStr := strings.Join(result.LegacyAnswers(), ";")
+	return a.writer.Write([]string{result.Subdomain, result.Type, result.RCode,
+		result.Timestamp.Format(time.RFC3339), answersStr,
+		joinMX(result.Answers), joinSOA(result.Answers), joinSRV(result.Answers), joinCAA(result.Answers)})
+}
+
+func (a *csvArtifact) End(w io.Writer) error {
+	a.writer.Flush()
+	return a.writer.Error()
+}
+
+// joinMX 将MX记录格式化为 "优先级 主机" 并用分号连接
+func joinMX(records []result.Record) string {
+	var items []string
+	for _, r := range records {
+		if r.MX != nil {
+			items = append(items, fmt.Sprintf("%d %s", r.MX.Preference, r.MX.Exchange))
+		}
+	}
+	return strings.Join(items, ";")
+}
+
+// joinSOA 将SOA记录的全部字段按空格连接
+func joinSOA(records []result.Record) string {
+	var items []string
+	for _, r := range records {
+		if r.SOA != nil {
+			soa := r.SOA
+			items = append(items, fmt.Sprintf("%s %s %d %d %d %d %d",
+				soa.MName, soa.RName, soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum))
+		}
 	}
+	return strings.Join(items, ";")
+}
 
-	// 写入数据行
-	for _, result := range results {
-		// 将Answers数组转换为单个字符串，用分号分隔
-		answersStr := ""
-		if len(result.Answers) > 0 {
-			answersStr = result.Answers[0]
-			for i := 1; i < len(result.Answers); i++ {
-				answersStr += ";" + result.Answers[i]
-			}
+// joinSRV 将SRV记录格式化为 "优先级 权重 端口 目标"
+func joinSRV(records []result.Record) string {
+	var items []string
+	for _, r := range records {
+		if r.SRV != nil {
+			items = append(items, fmt.Sprintf("%d %d %d %s", r.SRV.Priority, r.SRV.Weight, r.SRV.Port, r.SRV.Target))
 		}
+	}
+	return strings.Join(items, ";")
+}
 
-		err = writer.Write([]string{result.Subdomain, answersStr})
-		if err != nil {
-			gologger.Errorf("写入CSV数据行失败: %v", err)
-			continue
+// joinCAA 将CAA记录格式化为 "标志 标签 值"
+func joinCAA(records []result.Record) string {
+	var items []string
+	for _, r := range records {
+		if r.CAA != nil {
+			items = append(items, fmt.Sprintf("%d %s %s", r.CAA.Flags, r.CAA.Tag, r.CAA.Value))
 		}
 	}
-	writer.Flush()
-	gologger.Infof("CSV文件写入成功，共写入 %d 条记录", len(results))
-	return nil
+	return strings.Join(items, ";")
 }
@@ -1,52 +1,57 @@
 package output
 
 import (
-	"os"
+	"io"
 	"strings"
 
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
-
-	"github.com/boy-hack/ksubdomain/v2/pkg/utils"
 )
 
+// FileOutPut txt格式输出，扫描过程中实时写入 <文件名>.live，结束时过滤后原子写入最终文件
 type FileOutPut struct {
-	output         *os.File
+	spool          *spool
 	wildFilterMode string
-	domains        []result.Result
 	filename       string
 }
 
 func NewPlainOutput(filename string, wildFilterMode string) (*FileOutPut, error) {
-	output, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0664)
+	s, err := newSpool(filename+LiveSuffix, txtArtifact{})
 	if err != nil {
 		return nil, err
 	}
 	f := new(FileOutPut)
-	f.output = output
+	f.spool = s
 	f.wildFilterMode = wildFilterMode
 	f.filename = filename
-	return f, err
+	return f, nil
 }
+
 func (f *FileOutPut) WriteDomainResult(domain result.Result) error {
-	var msg string
-	var domains []string = []string{domain.Subdomain}
-	for _, item := range domain.Answers {
-		domains = append(domains, item)
-	}
-	msg = strings.Join(domains, "=>")
-	_, err := f.output.WriteString(msg + "\n")
-	f.domains = append(f.domains, domain)
-	return err
+	return f.spool.Add(domain)
 }
+
 func (f *FileOutPut) Close() error {
-	f.output.Close()
-	results := utils.WildFilterOutputResult(f.wildFilterMode, f.domains)
-	buf := strings.Builder{}
-	for _, item := range results {
-		buf.WriteString(item.Subdomain + "=>")
-		buf.WriteString(strings.Join(item.Answers, "=>"))
-		buf.WriteString("\n")
+	n, err := f.spool.Finalize(f.filename, f.wildFilterMode, txtArtifact{})
+	if err != nil {
+		return err
 	}
-	err := os.WriteFile(f.filename, []byte(buf.String()), 0664)
+	gologger.Infof("写入txt文件:%s count:%d", f.filename, n)
+	return nil
+}
+
+// txtArtifact 每行一个结果：子域名=>记录1=>记录2
+type txtArtifact struct{}
+
+func (txtArtifact) Begin(io.Writer) error { return nil }
+
+func (txtArtifact) Write(w io.Writer, item result.Result) error {
+	buf := strings.Builder{}
+	buf.WriteString(item.Subdomain + "=>")
+	buf.WriteString(strings.Join(item.LegacyAnswers(), "=>"))
+	buf.WriteString("\n")
+	_, err := io.WriteString(w, buf.String())
 	return err
 }
+
+func (txtArtifact) End(io.Writer) error { return nil }
@@ -2,43 +2,70 @@ package output
 
 import (
 	"encoding/json"
-	"os"
+	"io"
 
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
-
-	"github.com/boy-hack/ksubdomain/v2/pkg/utils"
 )
 
+// JsonOutPut json格式输出，扫描过程中实时写入 <文件名>.live，结束时过滤后原子写入最终文件
 type JsonOutPut struct {
-	domains        []result.Result
+	spool          *spool
 	filename       string
 	wildFilterMode string
 }
 
-func NewJsonOutput(filename string, wildFilterMode string) *JsonOutPut {
+func NewJsonOutput(filename string, wildFilterMode string) (*JsonOutPut, error) {
+	s, err := newSpool(filename+LiveSuffix, nil)
+	if err != nil {
+		return nil, err
+	}
 	f := new(JsonOutPut)
-	f.domains = make([]result.Result, 0)
+	f.spool = s
 	f.filename = filename
 	f.wildFilterMode = wildFilterMode
-	return f
+	return f, nil
 }
 
 func (f *JsonOutPut) WriteDomainResult(domain result.Result) error {
-	f.domains = append(f.domains, domain)
-	return nil
+	return f.spool.Add(domain)
 }
 
 func (f *JsonOutPut) Close() error {
-	gologger.Infof("写入json文件:%s count:%d", f.filename, len(f.domains))
-	if len(f.domains) > 0 {
-		results := utils.WildFilterOutputResult(f.wildFilterMode, f.domains)
-		jsonBytes, err := json.Marshal(results)
-		if err != nil {
-			return err
-		}
-		err = os.WriteFile(f.filename, jsonBytes, 0664)
+	n, err := f.spool.Finalize(f.filename, f.wildFilterMode, &jsonArtifact{})
+	if err != nil {
 		return err
 	}
+	gologger.Infof("写入json文件:%s count:%d", f.filename, n)
 	return nil
 }
+
+// jsonArtifact 以JSON数组写入全部结果，逐条编码
+type jsonArtifact struct {
+	n int
+}
+
+func (a *jsonArtifact) Begin(w io.Writer) error {
+	_, err := io.WriteString(w, "[")
+	return err
+}
+
+func (a *jsonArtifact) Write(w io.Writer, res result.Result) error {
+	data, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	if a.n > 0 {
+		if _, err = io.WriteString(w, ","); err != nil {
+			return err
+		}
+	}
+	a.n++
+	_, err = w.Write(data)
+	return err
+}
+
+func (a *jsonArtifact) End(w io.Writer) error {
+	_, err := io.WriteString(w, "]")
+	return err
+}
@@ -0,0 +1,88 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+)
+
+// StdoutFilename 输出文件名为 - 时写入标准输出
+const StdoutFilename = "-"
+
+// JsonlOutput 以JSON Lines格式实时写入结果，每条结果一行并立即刷新
+type JsonlOutput struct {
+	mu      sync.Mutex
+	file    io.WriteCloser
+	writer  *bufio.Writer
+	encoder *json.Encoder
+	summary bool
+}
+
+// jsonlSummary 汇总记录，与结果行通过 summary 字段区分
+type jsonlSummary struct {
+	Summary result.Summary `json:"summary"`
+}
+
+// NewJsonlOutput 创建JSON Lines输出，filename 为 - 时写入标准输出，summary 为true时在结束时追加一行统计信息
+func NewJsonlOutput(filename string, summary bool) (*JsonlOutput, error) {
+	var file io.WriteCloser
+	if filename == StdoutFilename {
+		file = nopCloser{os.Stdout}
+	} else {
+		f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0664)
+		if err != nil {
+			return nil, err
+		}
+		file = f
+	}
+	j := &JsonlOutput{
+		file:    file,
+		writer:  bufio.NewWriter(file),
+		summary: summary,
+	}
+	j.encoder = json.NewEncoder(j.writer)
+	return j, nil
+}
+
+func (j *JsonlOutput) WriteDomainResult(domain result.Result) error {
+	return j.writeLine(domain)
+}
+
+// WriteSummary 写入统计信息，未开启汇总时忽略
+func (j *JsonlOutput) WriteSummary(summary result.Summary) error {
+	if !j.summary {
+		return nil
+	}
+	return j.writeLine(jsonlSummary{Summary: summary})
+}
+
+func (j *JsonlOutput) writeLine(v interface{}) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	// Encoder 会在每个对象后追加换行
+	if err := j.encoder.Encode(v); err != nil {
+		return err
+	}
+	return j.writer.Flush()
+}
+
+func (j *JsonlOutput) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.writer.Flush(); err != nil {
+		j.file.Close()
+		return err
+	}
+	return j.file.Close()
+}
+
+// nopCloser 关闭时不关闭标准输出
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
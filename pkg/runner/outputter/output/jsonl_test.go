@@ -0,0 +1,51 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJsonlOutput(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "out.jsonl")
+	out, err := NewJsonlOutput(filename, true)
+	assert.NoError(t, err)
+
+	err = out.WriteDomainResult(result.Result{
+		Subdomain: "www.example.com",
+		Type:      "A",
+		RCode:     "NOERROR",
+		Answers:   []result.Record{{Type: "A", Value: "1.1.1.1", TTL: 60}},
+	})
+	assert.NoError(t, err)
+
+	// 写入后无需关闭即可读到完整的一行
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	var got result.Result
+	assert.NoError(t, json.Unmarshal(data, &got))
+	assert.Equal(t, "www.example.com", got.Subdomain)
+	assert.Equal(t, "1.1.1.1", got.Answers[0].Value)
+
+	assert.NoError(t, out.WriteSummary(result.Summary{Sent: 10, Success: 1, Finished: true}))
+	assert.NoError(t, out.Close())
+
+	f, err := os.Open(filename)
+	assert.NoError(t, err)
+	defer f.Close()
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	assert.Len(t, lines, 2)
+	var summary jsonlSummary
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &summary))
+	assert.Equal(t, uint64(10), summary.Summary.Sent)
+	assert.True(t, summary.Summary.Finished)
+}
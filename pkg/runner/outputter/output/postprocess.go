@@ -0,0 +1,218 @@
+package output
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/utils"
+)
+
+// LiveSuffix 实时结果文件的后缀，扫描中断时保留该文件
+const LiveSuffix = ".live"
+
+// artifactWriter 最终文件的格式化写入
+type artifactWriter interface {
+	Begin(w io.Writer) error
+	Write(w io.Writer, res result.Result) error
+	End(w io.Writer) error
+}
+
+// spool 结果的两级输出：扫描过程中每条结果实时写入暂存文件，
+// 结束时两遍读取暂存文件进行泛解析过滤后交给最终输出，
+// 整个过程不需要在内存中保存全部结果。
+// 实时文件默认就是 JSON Lines 格式的暂存文件；指定 live 格式时实时文件按该格式写出，
+// 过滤所需的完整结果另存在目标目录的临时文件中
+type spool struct {
+	liveName string
+	live     artifactWriter
+	liveFile *os.File
+	liveW    *bufio.Writer
+	dataName string
+	file     *os.File
+	writer   *bufio.Writer
+	encoder  *json.Encoder
+}
+
+// newSpool 创建实时结果文件，live 为nil时实时文件以 JSON Lines 保存完整结果
+func newSpool(liveName string, live artifactWriter) (*spool, error) {
+	s := &spool{liveName: liveName, live: live}
+	var err error
+	if live == nil {
+		s.file, err = os.OpenFile(liveName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0664)
+	} else {
+		s.file, err = os.CreateTemp(filepath.Dir(liveName), filepath.Base(liveName)+".data*")
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.dataName = s.file.Name()
+	s.writer = bufio.NewWriter(s.file)
+	s.encoder = json.NewEncoder(s.writer)
+	if live == nil {
+		return s, nil
+	}
+
+	s.liveFile, err = os.OpenFile(liveName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0664)
+	if err == nil {
+		s.liveW = bufio.NewWriter(s.liveFile)
+		err = live.Begin(s.liveW)
+	}
+	if err != nil {
+		if s.liveFile != nil {
+			s.liveFile.Close()
+			os.Remove(liveName)
+		}
+		s.file.Close()
+		os.Remove(s.dataName)
+		return nil, err
+	}
+	return s, nil
+}
+
+// Add 实时写入一条结果
+func (s *spool) Add(res result.Result) error {
+	if err := s.encoder.Encode(res); err != nil {
+		return err
+	}
+	if err := s.writer.Flush(); err != nil {
+		return err
+	}
+	if s.live == nil {
+		return nil
+	}
+	if err := s.live.Write(s.liveW, res); err != nil {
+		return err
+	}
+	return s.liveW.Flush()
+}
+
+// Filter 停止写入，按过滤模式两遍读取实时文件，将保留的结果依次交给 fn，返回保留的结果数量
+func (s *spool) Filter(wildFilterMode string, fn func(res result.Result) error) (int, error) {
+	if err := s.close(); err != nil {
+		return 0, err
+	}
+
+	filter, err := utils.NewWildcardFilter(wildFilterMode)
+	if err != nil {
+		return 0, err
+	}
+	if filter.PostProcess() {
+		if err := s.each(func(res result.Result) error {
+			filter.Observe(res)
+			return nil
+		}); err != nil {
+			return 0, err
+		}
+	}
+	n := 0
+	err = s.each(func(res result.Result) error {
+		filtered, ok := filter.Filter(res)
+		if !ok {
+			return nil
+		}
+		n++
+		return fn(filtered)
+	})
+	return n, err
+}
+
+// Finalize 过滤实时结果并原子写入最终文件，成功后删除实时文件，返回写入的结果数量
+func (s *spool) Finalize(filename string, wildFilterMode string, aw artifactWriter) (int, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".tmp*")
+	if err != nil {
+		return 0, err
+	}
+	tmpName := tmp.Name()
+	n, err := s.writeArtifact(tmp, wildFilterMode, aw)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmpName, 0664)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return 0, err
+	}
+	if err = os.Rename(tmpName, filename); err != nil {
+		os.Remove(tmpName)
+		return 0, err
+	}
+	return n, s.Remove()
+}
+
+// close 刷新并关闭暂存文件和实时文件
+func (s *spool) close() error {
+	err := s.writer.Flush()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	if s.liveFile != nil {
+		if flushErr := s.liveW.Flush(); err == nil {
+			err = flushErr
+		}
+		if closeErr := s.liveFile.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// Remove 删除实时文件和暂存文件
+func (s *spool) Remove() error {
+	if s.dataName != s.liveName {
+		if err := os.Remove(s.dataName); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return os.Remove(s.liveName)
+}
+
+// writeArtifact 将过滤后的结果写入最终文件
+func (s *spool) writeArtifact(file *os.File, wildFilterMode string, aw artifactWriter) (int, error) {
+	w := bufio.NewWriter(file)
+	if err := aw.Begin(w); err != nil {
+		return 0, err
+	}
+	n, err := s.Filter(wildFilterMode, func(res result.Result) error {
+		return aw.Write(w, res)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if err := aw.End(w); err != nil {
+		return 0, err
+	}
+	return n, w.Flush()
+}
+
+// each 依次读取实时文件中的结果
+func (s *spool) each(fn func(res result.Result) error) error {
+	file, err := os.Open(s.dataName)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	for {
+		var res result.Result
+		err := decoder.Decode(&res)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = fn(res); err != nil {
+			return err
+		}
+	}
+}
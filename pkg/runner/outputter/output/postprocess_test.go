@@ -0,0 +1,69 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func ipResult(subdomain, ip string) result.Result {
+	return result.Result{
+		Subdomain: subdomain,
+		Type:      "A",
+		RCode:     "NOERROR",
+		Answers:   []result.Record{{Type: "A", Value: ip}},
+	}
+}
+
+func TestPlainOutputStreamsAndFilters(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "out.txt")
+	out, err := NewPlainOutput(filename, "basic")
+	assert.NoError(t, err)
+
+	// 泛解析IP占比超过阈值，应在最终文件中被过滤
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, out.WriteDomainResult(ipResult(fmt.Sprintf("w%d.example.com", i), "10.0.0.1")))
+	}
+	assert.NoError(t, out.WriteDomainResult(ipResult("www.example.com", "1.1.1.1")))
+
+	// 扫描过程中结果以纯文本实时写入 .live 文件，最终文件尚未生成
+	live, err := os.ReadFile(filename + LiveSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, strings.Count(string(live), "\n"))
+	assert.True(t, strings.HasSuffix(string(live), "\nwww.example.com=>1.1.1.1\n"))
+	_, err = os.Stat(filename)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, out.Close())
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	assert.Equal(t, "www.example.com=>1.1.1.1\n", string(data))
+	// 实时文件、暂存文件和临时文件均已清理
+	entries, err := os.ReadDir(filepath.Dir(filename))
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "out.txt", entries[0].Name())
+	}
+}
+
+func TestJsonOutputFinalize(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "out.json")
+	out, err := NewJsonOutput(filename, "none")
+	assert.NoError(t, err)
+	assert.NoError(t, out.WriteDomainResult(ipResult("a.example.com", "1.1.1.1")))
+	assert.NoError(t, out.WriteDomainResult(ipResult("b.example.com", "2.2.2.2")))
+	assert.NoError(t, out.Close())
+
+	data, err := os.ReadFile(filename)
+	assert.NoError(t, err)
+	var results []result.Result
+	assert.NoError(t, json.Unmarshal(data, &results))
+	assert.Len(t, results, 2)
+	assert.Equal(t, "b.example.com", results[1].Subdomain)
+}
@@ -25,7 +25,7 @@ func (s *ScreenOutput) WriteDomainResult(domain result.Result) error {
 	var msg string
 	var domains []string = []string{domain.Subdomain}
 	for _, item := range domain.Answers {
-		domains = append(domains, item)
+		domains = append(domains, item.String())
 	}
 	msg = strings.Join(domains, " => ")
 	if !s.silent {
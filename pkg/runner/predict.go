@@ -0,0 +1,144 @@
+package runner
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+)
+
+// 预测候选数量的默认上限，Options 中的值小于等于0时使用
+const (
+	DefaultPredictPerSeed = 5000
+	DefaultPredictMax     = 1000000
+)
+
+// predictor 预测模式状态，整个扫描共享同一份规则和去重集合
+type predictor struct {
+	rules   *predict.Rules
+	perSeed int   // 每个种子最多投递的候选数量
+	max     int64 // 整个扫描最多投递的候选数量
+
+	mu   sync.Mutex
+	seen map[uint64]bool // 已发送或已解析的域名哈希，值为true表示由预测投递且尚未命中
+
+	sent int64 // 已投递的预测候选数量
+	hits int64 // 解析成功的预测候选数量
+}
+
+func newPredictor(rules *predict.Rules, perSeed, max int) *predictor {
+	if perSeed <= 0 {
+		perSeed = DefaultPredictPerSeed
+	}
+	if max <= 0 {
+		max = DefaultPredictMax
+	}
+	return &predictor{
+		rules:   rules,
+		perSeed: perSeed,
+		max:     int64(max),
+		seen:    make(map[uint64]bool),
+	}
+}
+
+func domainHash(domain string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(domain))
+	return h.Sum64()
+}
+
+// observe 记录一个解析成功的域名，返回该域名是否为首次作为种子出现；
+// 域名由预测投递时计为一次命中
+func (p *predictor) observe(domain string) bool {
+	key := domainHash(domain)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	predicted, ok := p.seen[key]
+	if predicted {
+		atomic.AddInt64(&p.hits, 1)
+	}
+	p.seen[key] = false
+	return !ok || predicted
+}
+
+// claim 标记候选为已发送，已出现过的候选返回false
+func (p *predictor) claim(domain string) bool {
+	key := domainHash(domain)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.seen[key]; ok {
+		return false
+	}
+	p.seen[key] = true
+	return true
+}
+
+// reserve 占用一个全局名额，超出上限时返回false
+func (p *predictor) reserve() bool {
+	if atomic.AddInt64(&p.sent, 1) > p.max {
+		atomic.AddInt64(&p.sent, -1)
+		return false
+	}
+	return true
+}
+
+// Sent 已投递的预测候选数量
+func (p *predictor) Sent() uint64 {
+	return uint64(atomic.LoadInt64(&p.sent))
+}
+
+// Hits 解析成功的预测候选数量
+func (p *predictor) Hits() uint64 {
+	return uint64(atomic.LoadInt64(&p.hits))
+}
+
+// predict 根据解析成功的域名预测新的子域名，去重后投递到发送通道
+func (r *Runner) predict(ctx context.Context, domain string) {
+	p := r.predictor
+	if p == nil || !p.observe(domain) {
+		return
+	}
+	r.predictSeed(ctx, domain)
+}
+
+// predictSeed 为种子生成候选并投递到发送通道
+func (r *Runner) predictSeed(ctx context.Context, domain string) {
+	p := r.predictor
+	atomic.AddInt64(&r.predictPending, 1)
+	r.producers.Add(1)
+	go func() {
+		defer r.producers.Done()
+		defer atomic.AddInt64(&r.predictPending, -1)
+		n := 0
+		p.rules.Generate(domain, func(candidate string) bool {
+			if n >= p.perSeed {
+				return false
+			}
+			if !p.claim(candidate) || r.queued(candidate) {
+				return true
+			}
+			if !p.reserve() {
+				return false
+			}
+			if ctx.Err() != nil {
+				return false
+			}
+			select {
+			case r.domainChan <- candidate:
+				n++
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		})
+	}()
+}
+
+// queued 候选是否已在等待响应
+func (r *Runner) queued(domain string) bool {
+	_, ok := r.statusDB.Get(statusdb.Key(domain, r.queryTypes[0]))
+	return ok
+}
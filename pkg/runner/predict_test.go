@@ -0,0 +1,82 @@
+package runner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPredictorDedupe(t *testing.T) {
+	p := newPredictor(nil, 0, 2)
+	assert.Equal(t, DefaultPredictPerSeed, p.perSeed)
+
+	// 解析成功的域名只作为一次种子，且不会再被预测投递
+	assert.True(t, p.observe("www.example.com"))
+	assert.False(t, p.observe("www.example.com"))
+	assert.False(t, p.claim("www.example.com"))
+
+	// 候选只投递一次
+	assert.True(t, p.claim("dev.www.example.com"))
+	assert.False(t, p.claim("dev.www.example.com"))
+
+	// 预测候选解析成功计为命中，并可继续作为种子
+	assert.True(t, p.observe("dev.www.example.com"))
+	assert.False(t, p.observe("dev.www.example.com"))
+	assert.Equal(t, uint64(1), p.Hits())
+
+	// 全局上限
+	assert.True(t, p.reserve())
+	assert.True(t, p.reserve())
+	assert.False(t, p.reserve())
+	assert.Equal(t, uint64(2), p.Sent())
+}
+
+func TestPredictPerSeed(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rules, err := predict.ParseRules("node{num:1-10}.{subdomain}.{domain}", "")
+	assert.NoError(t, err)
+	r := &Runner{
+		statusDB:   statusdb.CreateMemoryDB(),
+		queryTypes: []layers.DNSType{layers.DNSTypeA},
+		domainChan: make(chan string, 20),
+		predictor:  newPredictor(rules, 3, 0),
+	}
+	// 已在等待响应的候选不再投递
+	r.statusDB.Add(statusdb.Key("node1.www.example.com", layers.DNSTypeA), statusdb.Item{Domain: "node1.www.example.com"})
+
+	r.predict(ctx, "www.example.com")
+	r.predict(ctx, "www.example.com")
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&r.predictPending) == 0 }, time.Second, 10*time.Millisecond)
+	close(r.domainChan)
+	var domains []string
+	for domain := range r.domainChan {
+		domains = append(domains, domain)
+	}
+	assert.Equal(t, []string{"node2.www.example.com", "node3.www.example.com", "node4.www.example.com"}, domains)
+	assert.Equal(t, uint64(3), r.predictor.Sent())
+}
+
+func TestPredictStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rules, err := predict.ParseRules("node{num:1-10}.{subdomain}.{domain}", "")
+	assert.NoError(t, err)
+	r := &Runner{
+		statusDB:   statusdb.CreateMemoryDB(),
+		queryTypes: []layers.DNSType{layers.DNSTypeA},
+		domainChan: make(chan string),
+		predictor:  newPredictor(rules, 0, 0),
+	}
+	// 无人读取的通道上投递被阻塞，取消后协程退出，之后关闭通道不会被写入
+	r.predict(ctx, "www.example.com")
+	cancel()
+	r.producers.Wait()
+	close(r.domainChan)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&r.predictPending))
+}
@@ -0,0 +1,167 @@
+package runner
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/google/gopacket/layers"
+	"go.uber.org/ratelimit"
+)
+
+// probeKey 探测查询的标识，响应按 (解析器, 域名, 类型) 匹配
+type probeKey struct {
+	resolver string
+	name     string
+	qtype    layers.DNSType
+}
+
+// probeReply 探测查询的响应
+type probeReply struct {
+	rcode   layers.DNSResponseCode
+	answers []result.Record
+}
+
+// rawProber 使用扫描的发包方式发送独立于扫描队列的少量查询，
+// 用于解析器检测和泛解析探测，拥有独立的监听端口和随机DNS ID
+type rawProber struct {
+	transport transport
+	dnsID     uint16
+	limiter   ratelimit.Limiter
+	authority *authority // 权威直连模式下发往权威服务器的探测经其发送队列限速，为nil时只使用 limiter
+
+	mu      sync.Mutex
+	pending map[probeKey]*probeBatch
+	done    chan struct{}
+}
+
+// probeBatch 一次 Exchange 调用中等待响应的查询
+type probeBatch struct {
+	replies  map[probeKey]probeReply
+	want     int
+	complete chan struct{}
+}
+
+// newRawProber 按发包方式 mode 创建独立的传输并开始接收响应
+func newRawProber(mode string, ether *device.EtherTable, rate int64) (*rawProber, error) {
+	if rate <= 0 {
+		rate = 1000
+	}
+	t, err := newTransport(mode, ether, 1, 1000)
+	if err != nil {
+		return nil, err
+	}
+	p := &rawProber{
+		transport: t,
+		dnsID:     uint16(rand.Intn(0x10000)),
+		limiter:   ratelimit.New(int(rate)),
+		pending:   make(map[probeKey]*probeBatch),
+		done:      make(chan struct{}),
+	}
+	go p.receive()
+	return p, nil
+}
+
+// Close 关闭传输并等待接收协程退出
+func (p *rawProber) Close() {
+	p.transport.Close()
+	<-p.done
+}
+
+// receive 接收响应并交给等待中的查询
+func (p *rawProber) receive() {
+	defer close(p.done)
+	for {
+		var resp dnsResponse
+		select {
+		case resp = <-p.transport.Responses():
+		case <-p.transport.Done():
+			return
+		}
+		if resp.dns.ID != p.dnsID {
+			continue
+		}
+		question := resp.dns.Questions[0]
+		key := probeKey{resolver: resp.resolver, name: string(question.Name), qtype: question.Type}
+		reply := probeReply{rcode: resp.dns.ResponseCode}
+		for _, rr := range resp.dns.Answers {
+			record, err := dnsRecord2Record(rr)
+			if err != nil {
+				continue
+			}
+			record.Resolver = resp.resolver
+			reply.answers = append(reply.answers, record)
+		}
+		p.mu.Lock()
+		if b, ok := p.pending[key]; ok {
+			delete(p.pending, key)
+			b.replies[key] = reply
+			if len(b.replies) == b.want {
+				close(b.complete)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Exchange 发送一批查询，未响应的查询最多重发 retry 次，每轮等待 timeout，返回已收到的响应
+func (p *rawProber) Exchange(ctx context.Context, queries []probeKey, timeout time.Duration, retry int) map[probeKey]probeReply {
+	b := &probeBatch{
+		replies:  make(map[probeKey]probeReply, len(queries)),
+		complete: make(chan struct{}),
+	}
+	p.mu.Lock()
+	for _, q := range queries {
+		if _, ok := p.pending[q]; !ok {
+			p.pending[q] = b
+			b.want++
+		}
+	}
+	if b.want == 0 {
+		close(b.complete)
+	}
+	p.mu.Unlock()
+
+	for round := 0; round <= retry; round++ {
+		for _, q := range queries {
+			p.mu.Lock()
+			_, answered := b.replies[q]
+			p.mu.Unlock()
+			if answered {
+				continue
+			}
+			if ctx.Err() != nil {
+				break
+			}
+			if p.authority != nil && p.authority.isAuthoritative(q.resolver) {
+				// 与扫描查询一样不设置RD位，并计入该权威服务器的配额
+				q := q
+				p.authority.enqueue(ctx, q.resolver, func() {
+					p.transport.Send(q.name, q.resolver, p.dnsID, p.transport.Ports()[0], q.qtype, false)
+				})
+				continue
+			}
+			p.limiter.Take()
+			p.transport.Send(q.name, q.resolver, p.dnsID, p.transport.Ports()[0], q.qtype, true)
+		}
+		select {
+		case <-b.complete:
+		case <-ctx.Done():
+		case <-time.After(timeout):
+			continue
+		}
+		break
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, q := range queries {
+		if p.pending[q] == b {
+			delete(p.pending, q)
+		}
+	}
+	return b.replies
+}
@@ -1,12 +1,17 @@
 package processbar
 
 type ProcessData struct {
-	SuccessIndex uint64
-	SendIndex    uint64
-	QueueLength  int64
-	RecvIndex    uint64
-	FaildIndex   uint64
-	Elapsed      int
+	SuccessIndex    uint64
+	SendIndex       uint64
+	QueueLength     int64
+	RecvIndex       uint64
+	FaildIndex      uint64
+	Elapsed         int
+	NoErrorIndex    uint64 // NOERROR响应数
+	NXDomainIndex   uint64 // NXDOMAIN响应数
+	ServFailIndex   uint64 // SERVFAIL响应数
+	RefusedIndex    uint64 // REFUSED响应数
+	OtherRcodeIndex uint64 // 其他响应码数
 }
 type ProcessBar interface {
 	WriteData(data *ProcessData)
@@ -8,7 +8,9 @@ type ScreenProcess struct {
 
 func (s *ScreenProcess) WriteData(data *ProcessData) {
 	if !s.Silent {
-		fmt.Printf("\rSuccess:%d Send:%d Queue:%d Accept:%d Fail:%d Elapsed:%ds", data.SuccessIndex, data.SendIndex, data.QueueLength, data.RecvIndex, data.FaildIndex, data.Elapsed)
+		fmt.Printf("\rSuccess:%d Send:%d Queue:%d Accept:%d Fail:%d NX:%d ServFail:%d Refused:%d Elapsed:%ds",
+			data.SuccessIndex, data.SendIndex, data.QueueLength, data.RecvIndex, data.FaildIndex,
+			data.NXDomainIndex, data.ServFailIndex, data.RefusedIndex, data.Elapsed)
 	}
 }
 
@@ -0,0 +1,110 @@
+package runner
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+)
+
+// recursion 递归枚举状态，只在结果处理协程中访问
+type recursion struct {
+	words   []string            // 下一级爆破字典
+	seen    map[string]struct{} // 已作为种子的域名
+	waiting []string            // 等待泛解析探测结果的种子
+}
+
+func newRecursion(words []string) *recursion {
+	if len(words) == 0 {
+		words = core.GetDefaultSubNextData()
+	}
+	return &recursion{
+		words: words,
+		seen:  make(map[string]struct{}),
+	}
+}
+
+// domainLevel 返回域名相对所属根域名的层级，如 a.b.example.com 相对 example.com 为2，
+// 不属于任何根域名时返回-1
+func domainLevel(domain string, roots []string) int {
+	level := -1
+	labels := strings.Count(domain, ".")
+	for _, root := range roots {
+		if domain != root && !strings.HasSuffix(domain, "."+root) {
+			continue
+		}
+		// 取最接近的根域名
+		if l := labels - strings.Count(root, "."); level == -1 || l < level {
+			level = l
+		}
+	}
+	return level
+}
+
+// recurse 对解析成功且未达到最大深度的域名生成下一级候选，跳过泛解析区域
+func (r *Runner) recurse(ctx context.Context, domain string) {
+	if r.recursion == nil {
+		return
+	}
+	level := domainLevel(domain, r.options.RootDomains)
+	if level < 1 || level >= r.options.Depth {
+		return
+	}
+	if _, ok := r.recursion.seen[domain]; ok {
+		return
+	}
+	r.recursion.seen[domain] = struct{}{}
+	r.recurseSeed(ctx, domain)
+}
+
+// recurseSeed 确认种子不是泛解析区域后投递候选，区域尚未探测时等待
+func (r *Runner) recurseSeed(ctx context.Context, domain string) {
+	if r.wildcard != nil {
+		zone := r.wildcard.zone(ctx, domain)
+		if zone == nil {
+			r.recursion.waiting = append(r.recursion.waiting, domain)
+			atomic.AddInt64(&r.recursionWaiting, 1)
+			return
+		}
+		if zone.Wildcard {
+			gologger.Debugf("%s 为泛解析区域，跳过递归枚举\n", domain)
+			return
+		}
+	}
+	r.feedRecursive(ctx, domain)
+}
+
+// retryWaitingSeeds 泛解析探测完成后重新处理等待中的种子
+func (r *Runner) retryWaitingSeeds(ctx context.Context) {
+	if r.recursion == nil || len(r.recursion.waiting) == 0 {
+		return
+	}
+	waiting := r.recursion.waiting
+	r.recursion.waiting = nil
+	for _, domain := range waiting {
+		r.recurseSeed(ctx, domain)
+		atomic.AddInt64(&r.recursionWaiting, -1)
+	}
+}
+
+// feedRecursive 将 word.domain 候选投递到发送通道
+func (r *Runner) feedRecursive(ctx context.Context, domain string) {
+	atomic.AddInt64(&r.recursivePending, 1)
+	r.producers.Add(1)
+	go func() {
+		defer r.producers.Done()
+		defer atomic.AddInt64(&r.recursivePending, -1)
+		for _, word := range r.recursion.words {
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case r.domainChan <- word + "." + domain:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,65 @@
+package runner
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainLevel(t *testing.T) {
+	roots := []string{"example.com", "dev.example.com"}
+	assert.Equal(t, 0, domainLevel("example.com", roots))
+	assert.Equal(t, 1, domainLevel("www.example.com", roots))
+	assert.Equal(t, 1, domainLevel("a.dev.example.com", roots))
+	assert.Equal(t, 2, domainLevel("a.b.example.com", roots))
+	assert.Equal(t, -1, domainLevel("www.example.org", roots))
+}
+
+func TestRecurse(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	r := &Runner{
+		options:    &options.Options{RootDomains: []string{"example.com"}, Depth: 2},
+		domainChan: make(chan string, 10),
+		recursion:  newRecursion([]string{"dev", "test"}),
+		wildcard:   newWildcardDetector(),
+	}
+
+	// 种子所在区域尚未探测，等待探测结果
+	r.recurse(ctx, "www.example.com")
+	assert.Equal(t, []string{"www.example.com"}, <-r.wildcard.requests)
+	assert.Equal(t, int64(1), r.recursionWaiting)
+
+	r.wildcard.finish(map[string]*WildcardZone{"www.example.com": newWildcardZone("www.example.com")})
+	r.retryWaitingSeeds(ctx)
+	assert.Equal(t, int64(0), r.recursionWaiting)
+	assert.ElementsMatch(t, []string{"dev.www.example.com", "test.www.example.com"},
+		[]string{<-r.domainChan, <-r.domainChan})
+
+	// 泛解析区域和超过深度的域名不再递归
+	wild := newWildcardZone("api.example.com")
+	wild.Wildcard = true
+	r.wildcard.finish(map[string]*WildcardZone{"api.example.com": wild})
+	r.recurse(ctx, "api.example.com")
+	r.recurse(ctx, "dev.www.example.com")
+	r.recurse(ctx, "www.example.com")
+	assert.Len(t, r.domainChan, 0)
+}
+
+func TestRecursiveStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &Runner{
+		options:    &options.Options{RootDomains: []string{"example.com"}, Depth: 2},
+		domainChan: make(chan string),
+		recursion:  newRecursion([]string{"dev", "test"}),
+	}
+	// 无人读取的通道上投递被阻塞，取消后协程退出，之后关闭通道不会被写入
+	r.recurse(ctx, "www.example.com")
+	cancel()
+	r.producers.Wait()
+	close(r.domainChan)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&r.recursivePending))
+}
@@ -9,255 +9,430 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
 )
 
-// dnsRecord2String 将DNS记录转换为字符串
-func dnsRecord2String(rr layers.DNSResourceRecord) (string, error) {
+// dnsRecord2Record 将DNS记录转换为结构化的解析记录
+func dnsRecord2Record(rr layers.DNSResourceRecord) (result.Record, error) {
+	record := result.Record{
+		Type: options.QueryTypeName(rr.Type),
+		TTL:  rr.TTL,
+	}
 	if rr.Class == layers.DNSClassIN {
 		switch rr.Type {
 		case layers.DNSTypeA, layers.DNSTypeAAAA:
 			if rr.IP != nil {
-				return rr.IP.String(), nil
+				record.Value = rr.IP.String()
+				return record, nil
 			}
 		case layers.DNSTypeNS:
 			if rr.NS != nil {
-				return "NS " + string(rr.NS), nil
+				record.Value = string(rr.NS)
+				return record, nil
 			}
 		case layers.DNSTypeCNAME:
 			if rr.CNAME != nil {
-				return "CNAME " + string(rr.CNAME), nil
+				record.Value = string(rr.CNAME)
+				return record, nil
 			}
 		case layers.DNSTypePTR:
 			if rr.PTR != nil {
-				return "PTR " + string(rr.PTR), nil
+				record.Value = string(rr.PTR)
+				return record, nil
 			}
 		case layers.DNSTypeTXT:
 			if rr.TXT != nil {
-				return "TXT " + string(rr.TXT), nil
+				record.Value = string(rr.TXT)
+				return record, nil
+			}
+		case layers.DNSTypeMX:
+			if rr.MX.Name != nil {
+				record.MX = &result.MXRecord{
+					Preference: rr.MX.Preference,
+					Exchange:   string(rr.MX.Name),
+				}
+				record.Value = fmt.Sprintf("%d %s", rr.MX.Preference, rr.MX.Name)
+				return record, nil
+			}
+		case layers.DNSTypeSOA:
+			if rr.SOA.MName != nil {
+				soa := rr.SOA
+				record.SOA = &result.SOARecord{
+					MName:   string(soa.MName),
+					RName:   string(soa.RName),
+					Serial:  soa.Serial,
+					Refresh: soa.Refresh,
+					Retry:   soa.Retry,
+					Expire:  soa.Expire,
+					Minimum: soa.Minimum,
+				}
+				record.Value = fmt.Sprintf("%s %s %d %d %d %d %d", soa.MName, soa.RName,
+					soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum)
+				return record, nil
+			}
+		case layers.DNSTypeSRV:
+			if rr.SRV.Name != nil {
+				record.SRV = &result.SRVRecord{
+					Priority: rr.SRV.Priority,
+					Weight:   rr.SRV.Weight,
+					Port:     rr.SRV.Port,
+					Target:   string(rr.SRV.Name),
+				}
+				record.Value = fmt.Sprintf("%d %d %d %s", rr.SRV.Priority, rr.SRV.Weight, rr.SRV.Port, rr.SRV.Name)
+				return record, nil
+			}
+		case options.DNSTypeCAA:
+			caa, err := parseCAA(rr.Data)
+			if err == nil {
+				record.CAA = &caa
+				record.Value = fmt.Sprintf("%d %s %q", caa.Flags, caa.Tag, caa.Value)
+				return record, nil
 			}
 		}
 	}
-	return "", errors.New("dns record error")
+	return record, errors.New("dns record error")
 }
 
-// 预分配解码器对象池，避免频繁创建
-var decoderPool = sync.Pool{
-	New: func() interface{} {
-		var eth layers.Ethernet
-		var ipv4 layers.IPv4
-		var ipv6 layers.IPv6
-		var udp layers.UDP
-		var dns layers.DNS
-		parser := gopacket.NewDecodingLayerParser(
-			layers.LayerTypeEthernet, &eth, &ipv4, &ipv6, &udp, &dns)
-
-		return &decodingContext{
-			parser:  parser,
-			eth:     &eth,
-			ipv4:    &ipv4,
-			ipv6:    &ipv6,
-			udp:     &udp,
-			dns:     &dns,
-			decoded: make([]gopacket.LayerType, 0, 5),
-		}
-	},
+// dnsRecord2String 将DNS记录转换为旧版字符串形式
+func dnsRecord2String(rr layers.DNSResourceRecord) (string, error) {
+	record, err := dnsRecord2Record(rr)
+	if err != nil {
+		return "", err
+	}
+	return record.String(), nil
+}
+
+// rcodeName 返回响应码的标准助记符
+func rcodeName(rcode layers.DNSResponseCode) string {
+	switch rcode {
+	case layers.DNSResponseCodeNoErr:
+		return "NOERROR"
+	case layers.DNSResponseCodeFormErr:
+		return "FORMERR"
+	case layers.DNSResponseCodeServFail:
+		return "SERVFAIL"
+	case layers.DNSResponseCodeNXDomain:
+		return "NXDOMAIN"
+	case layers.DNSResponseCodeNotImp:
+		return "NOTIMP"
+	case layers.DNSResponseCodeRefused:
+		return "REFUSED"
+	}
+	return fmt.Sprintf("RCODE%d", rcode)
+}
+
+// parseCAA 解析CAA记录的RDATA，gopacket不会解码该类型
+func parseCAA(data []byte) (result.CAARecord, error) {
+	var caa result.CAARecord
+	if len(data) < 2 {
+		return caa, errors.New("CAA too small")
+	}
+	tagLen := int(data[1])
+	if len(data) < 2+tagLen {
+		return caa, errors.New("CAA tag length error")
+	}
+	caa.Flags = data[0]
+	caa.Tag = string(data[2 : 2+tagLen])
+	caa.Value = string(data[2+tagLen:])
+	return caa, nil
 }
 
 // decodingContext 解码上下文
 type decodingContext struct {
-	parser  *gopacket.DecodingLayerParser
+	parser  *device.LinkParser
 	eth     *layers.Ethernet
 	ipv4    *layers.IPv4
 	ipv6    *layers.IPv6
 	udp     *layers.UDP
-	dns     *layers.DNS
 	decoded []gopacket.LayerType
 }
 
-// 解析DNS响应包并处理
-func (r *Runner) processPacket(data []byte, dnsChanel chan<- layers.DNS) {
-	// 从对象池获取解码器
-	dc := decoderPool.Get().(*decodingContext)
-	defer decoderPool.Put(dc)
+// newDecodingContext 按抓包句柄的链路封装方式创建解码上下文，每个解码协程持有一个，不在协程间共享
+func newDecodingContext(framing device.Framing) *decodingContext {
+	var eth layers.Ethernet
+	var loop layers.Loopback
+	var sll layers.LinuxSLL
+	var ipv4 layers.IPv4
+	var ipv6 layers.IPv6
+	var udp layers.UDP
+	parser := device.NewLinkParser(framing, &eth, &loop, &sll, &ipv4, &ipv6, &udp)
+
+	return &decodingContext{
+		parser:  parser,
+		eth:     &eth,
+		ipv4:    &ipv4,
+		ipv6:    &ipv6,
+		udp:     &udp,
+		decoded: make([]gopacket.LayerType, 0, 5),
+	}
+}
+
+// dnsResponse 带有来源解析器地址的DNS响应
+type dnsResponse struct {
+	dns      layers.DNS
+	resolver string
+	port     uint16 // 响应的目的端口，即发送查询时的源端口
+}
 
+// decode 解码DNS响应包，返回的响应不引用解码上下文中的内存
+func (dc *decodingContext) decode(data []byte) (dnsResponse, bool) {
 	// 清空解码层类型切片
 	dc.decoded = dc.decoded[:0]
 
-	// 解析数据包
+	// 解析到UDP层为止，DNS层直接从UDP负载解码
 	err := dc.parser.DecodeLayers(data, &dc.decoded)
 	if err != nil {
-		return
+		return dnsResponse{}, false
+	}
+
+	// 记录响应来源
+	resp := dnsResponse{port: uint16(dc.udp.DstPort)}
+	udpFound := false
+	for _, layerType := range dc.decoded {
+		switch layerType {
+		case layers.LayerTypeIPv4:
+			resp.resolver = options.JoinResolver(dc.ipv4.SrcIP, uint16(dc.udp.SrcPort))
+		case layers.LayerTypeIPv6:
+			resp.resolver = options.JoinResolver(dc.ipv6.SrcIP, uint16(dc.udp.SrcPort))
+		case layers.LayerTypeUDP:
+			udpFound = true
+		}
+	}
+	if !udpFound || resp.resolver == "" {
+		return dnsResponse{}, false
+	}
+
+	// gopacket 只把53端口的UDP负载识别为DNS，其他端口的解析器同样从负载解码；
+	// 每个响应解码一份独立的副本，不引用解码上下文中的内存
+	if err = resp.dns.DecodeFromBytes(dc.udp.Payload, gopacket.NilDecodeFeedback); err != nil {
+		return dnsResponse{}, false
+	}
+
+	// 检查是否为DNS响应，且有查询问题
+	if !resp.dns.QR || len(resp.dns.Questions) == 0 {
+		return dnsResponse{}, false
+	}
+	return resp, true
+}
+
+// sameResolver 两个解析器地址是否相同，兼容省略默认端口的写法
+func sameResolver(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if options.IsEncryptedResolver(a) || options.IsEncryptedResolver(b) {
+		return false
+	}
+	ipA, portA := options.SplitResolver(a)
+	ipB, portB := options.SplitResolver(b)
+	return ipA != nil && ipA.Equal(ipB) && portA == portB
+}
+
+// isFinalRcode 只有NOERROR(含NODATA)和NXDOMAIN是确定的结果，其余响应码视为解析器异常
+func isFinalRcode(rcode layers.DNSResponseCode) bool {
+	return rcode == layers.DNSResponseCodeNoErr || rcode == layers.DNSResponseCodeNXDomain
+}
+
+// countRcode 按响应码分类计数
+func (r *Runner) countRcode(rcode layers.DNSResponseCode) {
+	switch rcode {
+	case layers.DNSResponseCodeNoErr:
+		atomic.AddUint64(&r.noErrorCount, 1)
+	case layers.DNSResponseCodeNXDomain:
+		atomic.AddUint64(&r.nxdomainCount, 1)
+	case layers.DNSResponseCodeServFail:
+		atomic.AddUint64(&r.servfailCount, 1)
+	case layers.DNSResponseCodeRefused:
+		atomic.AddUint64(&r.refusedCount, 1)
+	default:
+		atomic.AddUint64(&r.otherRcodeCount, 1)
 	}
+}
 
-	// 检查是否为DNS响应
-	if !dc.dns.QR {
+// handleResponse 处理单个DNS响应
+func (r *Runner) handleResponse(ctx context.Context, resp dnsResponse) {
+	dns := resp.dns
+	question := dns.Questions[0]
+	subdomain := string(question.Name)
+	key := statusdb.Key(subdomain, question.Type)
+
+	// 只接受来自所发往的解析器、与最近一次发送的 (问题, DNS ID, 源端口) 完全一致的响应，
+	// 校验和认领在同一把锁内完成，重复或重传的响应只有第一个被处理
+	item, ok := r.statusDB.Claim(key, func(item statusdb.Item) bool {
+		return item.ID == dns.ID && item.Port == resp.port && sameResolver(item.Dns, resp.resolver)
+	})
+	if !ok {
+		atomic.AddUint64(&r.rejectedCount, 1)
 		return
 	}
+	atomic.AddUint64(&r.receiveCount, 1)
+	r.countRcode(dns.ResponseCode)
+	r.recordResolverResponse(item, dns.ResponseCode)
 
-	// 确认DNS ID匹配
-	if dc.dns.ID != r.dnsID {
+	// SERVFAIL、REFUSED 多为解析器过载或限速，换一个解析器重新查询
+	if !isFinalRcode(dns.ResponseCode) {
+		r.requeue(ctx, key)
 		return
 	}
 
-	// 确认有查询问题
-	if len(dc.dns.Questions) == 0 {
+	// 权威服务器返回的引荐(referral)，登记子区域委派后改发给子区域的权威服务器
+	if r.authority != nil && r.authority.isAuthoritative(item.Dns) {
+		if zone, hosts, glue := referral(&dns, subdomain); zone != "" {
+			r.followReferral(ctx, key, item, zone, hosts, glue)
+			return
+		}
+	}
+
+	// 截断的响应交给TCP重查，先计入待处理再删除状态，避免扫描被提前判定为结束
+	// 加密传输本身基于TCP，不再重查
+	if dns.TC && !options.IsEncryptedResolver(resp.resolver) {
+		atomic.AddUint64(&r.truncatedCount, 1)
+		atomic.AddInt64(&r.tcpPending, 1)
+		r.statusDB.Del(key)
+		q := truncatedQuery{
+			subdomain: subdomain,
+			qtype:     question.Type,
+			resolver:  resp.resolver,
+			rcode:     dns.ResponseCode,
+			partial:   answerRecords(&dns, resp.resolver),
+		}
+		select {
+		case r.truncatedChan <- q:
+		case <-ctx.Done():
+			atomic.AddInt64(&r.tcpPending, -1)
+		}
 		return
 	}
 
-	// 记录接收包数量
-	atomic.AddUint64(&r.receiveCount, 1)
+	r.statusDB.Del(key)
+	if dns.ANCount > 0 {
+		atomic.AddUint64(&r.successCount, 1)
+		r.sendResult(ctx, result.Result{
+			Subdomain: subdomain,
+			Type:      options.QueryTypeName(question.Type),
+			RCode:     rcodeName(dns.ResponseCode),
+			Timestamp: time.Now(),
+			Answers:   answerRecords(&dns, resp.resolver),
+		})
+	}
+}
 
-	// 向处理通道发送DNS响应
+// sendResult 将结果交给结果处理协程，扫描取消后结果处理协程已退出，不再写入
+func (r *Runner) sendResult(ctx context.Context, res result.Result) {
 	select {
-	case dnsChanel <- *dc.dns:
+	case r.resultChan <- res:
+	case <-ctx.Done():
 	}
 }
 
-// recvChanel 实现接收DNS响应的功能
-func (r *Runner) recvChanel(ctx context.Context, wg *sync.WaitGroup) {
-	defer wg.Done()
+// answerRecords 转换响应中的应答记录，无法解析的记录被跳过
+func answerRecords(dns *layers.DNS, resolver string) []result.Record {
+	var records []result.Record
+	for _, v := range dns.Answers {
+		record, err := dnsRecord2Record(v)
+		if err != nil {
+			continue
+		}
+		record.Resolver = resolver
+		records = append(records, record)
+	}
+	return records
+}
+
+// recordResolverResponse 按响应码更新发送该查询的解析器统计
+func (r *Runner) recordResolverResponse(item statusdb.Item, rcode layers.DNSResponseCode) {
+	latency := time.Since(item.Time)
+	switch rcode {
+	case layers.DNSResponseCodeServFail:
+		r.resolverPool.OnServFail(item.Dns, latency)
+	case layers.DNSResponseCodeRefused:
+		r.resolverPool.OnRefused(item.Dns, latency)
+	default:
+		if isFinalRcode(rcode) {
+			r.resolverPool.OnAnswer(item.Dns, latency)
+		}
+	}
+}
+
+// requeue 将查询立即放回重试通道，超过最大重试次数则放弃
+func (r *Runner) requeue(ctx context.Context, key string) {
+	v, ok := r.statusDB.Get(key)
+	if !ok {
+		return
+	}
+	if r.retryExhausted(v) {
+		r.giveUp(key)
+		return
+	}
+	select {
+	case r.retryChan <- v:
+	case <-ctx.Done():
+	default:
+		// 重试通道已满，交给超时重试处理
+	}
+}
+
+// openRecvHandle 打开接收DNS响应的抓包句柄，过滤器由 captureMux 按订阅端口设置
+func openRecvHandle(deviceName string) (*pcap.Handle, error) {
 	var (
 		snapshotLen = 65536
 		timeout     = 5 * time.Second
-		err         error
 	)
-	inactive, err := pcap.NewInactiveHandle(r.options.EtherInfo.Device)
+	inactive, err := pcap.NewInactiveHandle(deviceName)
 	if err != nil {
-		gologger.Errorf("创建网络捕获句柄失败: %v", err)
-		return
-	}
-	err = inactive.SetSnapLen(snapshotLen)
-	if err != nil {
-		gologger.Errorf("设置抓包长度失败: %v", err)
-		return
+		return nil, fmt.Errorf("创建网络捕获句柄失败: %v", err)
 	}
 	defer inactive.CleanUp()
 
+	if err = inactive.SetSnapLen(snapshotLen); err != nil {
+		return nil, fmt.Errorf("设置抓包长度失败: %v", err)
+	}
 	if err = inactive.SetTimeout(timeout); err != nil {
-		gologger.Errorf("设置超时失败: %v", err)
-		return
+		return nil, fmt.Errorf("设置超时失败: %v", err)
 	}
-
-	err = inactive.SetImmediateMode(true)
-	if err != nil {
-		gologger.Errorf("设置即时模式失败: %v", err)
-		return
+	if err = inactive.SetImmediateMode(true); err != nil {
+		return nil, fmt.Errorf("设置即时模式失败: %v", err)
 	}
 
 	handle, err := inactive.Activate()
 	if err != nil {
-		gologger.Errorf("激活网络捕获失败: %v", err)
-		return
-	}
-	defer handle.Close()
-
-	err = handle.SetBPFFilter(fmt.Sprintf("udp and src port 53 and dst port %d", r.listenPort))
-	if err != nil {
-		gologger.Errorf("设置BPF过滤器失败: %v", err)
-		return
+		return nil, fmt.Errorf("激活网络捕获失败: %v", err)
 	}
+	return handle, nil
+}
 
-	// 创建DNS响应处理通道，缓冲大小适当增加
-	dnsChanel := make(chan layers.DNS, 10000)
+// recvChanel 从发包方式的响应通道中读取响应并处理
+func (r *Runner) recvChanel(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
 
 	// 使用多个协程处理DNS响应，提高并发效率
 	processorCount := runtime.NumCPU() * 2
 	var processorWg sync.WaitGroup
-	processorWg.Add(processorCount)
-
-	// 启动多个处理协程
-	for i := 0; i < processorCount; i++ {
+	processorWg.Add(processorCount + tcpRetryWorkers)
+	for i := 0; i < tcpRetryWorkers; i++ {
 		go func() {
 			defer processorWg.Done()
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case dns, ok := <-dnsChanel:
-					if !ok {
-						return
-					}
-
-					subdomain := string(dns.Questions[0].Name)
-					r.statusDB.Del(subdomain)
-					if dns.ANCount > 0 {
-						atomic.AddUint64(&r.successCount, 1)
-						var answers []string
-						for _, v := range dns.Answers {
-							answer, err := dnsRecord2String(v)
-							if err != nil {
-								continue
-							}
-							answers = append(answers, answer)
-						}
-						r.resultChan <- result.Result{
-							Subdomain: subdomain,
-							Answers:   answers,
-						}
-					}
-				}
-			}
+			r.tcpRetryWorker(ctx)
 		}()
 	}
-
-	// 使用多个接收协程读取网络数据包
-	packetChan := make(chan []byte, 10000)
-
-	// 启动数据包接收协程
-	go func() {
-		for {
-			data, _, err := handle.ReadPacketData()
-			if err != nil {
-				if errors.Is(err, pcap.NextErrorTimeoutExpired) {
-					continue
-				}
-				return
-			}
-
-			select {
-			case <-ctx.Done():
-				return
-			case packetChan <- data:
-				// 数据包已发送到处理通道
-			}
-		}
-	}()
-
-	// 启动多个数据包解析协程
-	parserCount := runtime.NumCPU() * 2
-	var parserWg sync.WaitGroup
-	parserWg.Add(parserCount)
-
-	for i := 0; i < parserCount; i++ {
+	for i := 0; i < processorCount; i++ {
 		go func() {
-			defer parserWg.Done()
+			defer processorWg.Done()
 			for {
 				select {
 				case <-ctx.Done():
 					return
-				case data, ok := <-packetChan:
-					if !ok {
-						return
-					}
-					r.processPacket(data, dnsChanel)
+				case resp := <-r.transport.Responses():
+					r.handleResponse(ctx, resp)
 				}
 			}
 		}()
 	}
-
-	// 等待上下文结束
-	<-ctx.Done()
-
-	// 关闭通道
-	close(packetChan)
-	close(dnsChanel)
-
-	// 等待所有处理和解析协程结束
-	parserWg.Wait()
 	processorWg.Wait()
 }
@@ -0,0 +1,85 @@
+package runner
+
+import (
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDnsRecord2String(t *testing.T) {
+	records := []struct {
+		rr     layers.DNSResourceRecord
+		answer string
+	}{
+		{
+			rr: layers.DNSResourceRecord{Type: layers.DNSTypeMX, Class: layers.DNSClassIN,
+				MX: layers.DNSMX{Preference: 10, Name: []byte("mail.example.com")}},
+			answer: "MX 10 mail.example.com",
+		},
+		{
+			rr: layers.DNSResourceRecord{Type: layers.DNSTypeSOA, Class: layers.DNSClassIN,
+				SOA: layers.DNSSOA{MName: []byte("ns1.example.com"), RName: []byte("admin.example.com"),
+					Serial: 1, Refresh: 2, Retry: 3, Expire: 4, Minimum: 5}},
+			answer: "SOA ns1.example.com admin.example.com 1 2 3 4 5",
+		},
+		{
+			rr: layers.DNSResourceRecord{Type: layers.DNSTypeSRV, Class: layers.DNSClassIN,
+				SRV: layers.DNSSRV{Priority: 1, Weight: 5, Port: 5060, Name: []byte("sip.example.com")}},
+			answer: "SRV 1 5 5060 sip.example.com",
+		},
+		{
+			rr: layers.DNSResourceRecord{Type: options.DNSTypeCAA, Class: layers.DNSClassIN,
+				Data: append([]byte{0, 5}, []byte("issueletsencrypt.org")...)},
+			answer: `CAA 0 issue "letsencrypt.org"`,
+		},
+	}
+	for _, item := range records {
+		answer, err := dnsRecord2String(item.rr)
+		assert.NoError(t, err)
+		assert.Equal(t, item.answer, answer)
+	}
+
+	var parsed []result.Record
+	for _, item := range records {
+		record, err := dnsRecord2Record(item.rr)
+		assert.NoError(t, err)
+		parsed = append(parsed, record)
+	}
+	assert.Equal(t, "mail.example.com", parsed[0].MX.Exchange)
+	assert.Equal(t, uint32(5), parsed[1].SOA.Minimum)
+	assert.Equal(t, uint16(5060), parsed[2].SRV.Port)
+	assert.Equal(t, "letsencrypt.org", parsed[3].CAA.Value)
+	assert.Equal(t, "CAA", parsed[3].Type)
+
+	_, err := parseCAA([]byte{0, 10, 'a'})
+	assert.Error(t, err)
+}
+
+func TestJudgeResolver(t *testing.T) {
+	controls := map[string][]string{"dns.google": {"8.8.8.8", "8.8.4.4"}}
+	a := func(ips ...string) *probeReply {
+		reply := &probeReply{rcode: layers.DNSResponseCodeNoErr}
+		for _, ip := range ips {
+			reply.answers = append(reply.answers, result.Record{Type: "A", Value: ip})
+		}
+		return reply
+	}
+	control := func(reply *probeReply) *resolverProbe {
+		return &resolverProbe{kind: probeControl, control: "dns.google", reply: reply}
+	}
+	probes := map[probeKey]*resolverProbe{
+		{"1.1.1.1", "dns.google", layers.DNSTypeA}:         control(a("8.8.8.8")),
+		{"1.1.1.1", "x1y2z3.example.com", layers.DNSTypeA}: {kind: probeNX, reply: &probeReply{rcode: layers.DNSResponseCodeNXDomain}},
+		{"6.6.6.6", "dns.google", layers.DNSTypeA}:         control(a("8.8.8.8")),
+		{"6.6.6.6", "a1b2c3.example.com", layers.DNSTypeA}: {kind: probeNX, reply: a("10.0.0.1")},
+		{"7.7.7.7", "dns.google", layers.DNSTypeA}:         control(a("10.0.0.1")),
+		{"9.9.9.9", "dns.google", layers.DNSTypeA}:         control(nil),
+	}
+	assert.True(t, judgeResolver("1.1.1.1", probes, controls).Valid)
+	assert.False(t, judgeResolver("6.6.6.6", probes, controls).Valid)
+	assert.False(t, judgeResolver("7.7.7.7", probes, controls).Valid)
+	assert.False(t, judgeResolver("9.9.9.9", probes, controls).Valid)
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+)
+
+// usableResolvers 剔除网卡无法发送的地址族对应的解析器
+func usableResolvers(resolvers []string, ether *device.EtherTable) []string {
+	ret := make([]string, 0, len(resolvers))
+	for _, resolver := range resolvers {
+		ip, _ := options.SplitResolver(resolver)
+		if ip == nil {
+			continue
+		}
+		if ip.To4() != nil && !ether.SupportsIPv4() {
+			gologger.Warningf("网卡 %s 不支持IPv4，忽略解析器 %s\n", ether.Device, resolver)
+			continue
+		}
+		if ip.To4() == nil && !ether.SupportsIPv6() {
+			gologger.Warningf("网卡 %s 不支持IPv6，忽略解析器 %s\n", ether.Device, resolver)
+			continue
+		}
+		ret = append(ret, resolver)
+	}
+	return ret
+}
+
+// rebalanceResolvers 重新计算解析器权重，并提示被剔除的解析器
+func (r *Runner) rebalanceResolvers() {
+	evicted := r.resolverPool.Rebalance()
+	for _, server := range evicted {
+		gologger.Warningf("解析器 %s 健康度过低，已停止使用\n", server)
+	}
+}
+
+// printResolverStats 输出每个解析器的统计信息
+func (r *Runner) printResolverStats() {
+	stats := r.resolverPool.Stats()
+	if len(stats) == 0 {
+		return
+	}
+	gologger.Infof("========== 解析器统计 ==========\n")
+	gologger.Infof("%-22s %10s %10s %8s %8s %8s %10s %7s\n",
+		"解析器", "发送", "响应", "超时", "SERVFAIL", "REFUSED", "平均延迟", "健康度")
+	for _, s := range stats {
+		if s.Sent == 0 {
+			continue
+		}
+		status := ""
+		if s.Evicted {
+			status = " (已剔除)"
+		}
+		gologger.Infof("%-22s %10d %10d %8d %8d %8d %10s %6.1f%%%s\n",
+			s.Server, s.Sent, s.Answered, s.Timeouts, s.ServFail, s.Refused,
+			s.AvgLatency.Round(time.Millisecond), s.Health*100, status)
+	}
+}
+
+// filterResolvers 按网卡支持的地址族过滤默认解析器和特殊解析器，udp 发包方式由系统选择出口，不需要过滤
+func filterResolvers(opt *options.Options) error {
+	if opt.EtherInfo == nil {
+		return nil
+	}
+	resolvers := usableResolvers(opt.Resolvers, opt.EtherInfo)
+	if len(resolvers) == 0 {
+		return fmt.Errorf("网卡 %s 没有可用的解析器", opt.EtherInfo.Device)
+	}
+	opt.Resolvers = resolvers
+	if len(opt.SpecialResolvers) == 0 {
+		return nil
+	}
+	special := make(map[string][]string, len(opt.SpecialResolvers))
+	for suffix, servers := range opt.SpecialResolvers {
+		if servers = usableResolvers(servers, opt.EtherInfo); len(servers) > 0 {
+			special[suffix] = servers
+		}
+	}
+	opt.SpecialResolvers = special
+	return nil
+}
+
+// selectTransport 解析器为 DoT/DoH 时切换到加密传输，加密解析器只用于验证模式，且不能与明文解析器混用
+func selectTransport(opt *options.Options) error {
+	encrypted, plain := 0, 0
+	count := func(servers []string) {
+		for _, server := range servers {
+			if options.IsEncryptedResolver(server) {
+				encrypted++
+			} else {
+				plain++
+			}
+		}
+	}
+	count(opt.Resolvers)
+	for _, servers := range opt.SpecialResolvers {
+		count(servers)
+	}
+	if encrypted == 0 {
+		return nil
+	}
+	if plain > 0 {
+		return fmt.Errorf("加密解析器(tls://、https://)不能与明文解析器混用")
+	}
+	if opt.Method != options.VerifyType {
+		return fmt.Errorf("加密解析器只支持验证模式")
+	}
+	opt.Transport = options.TransportEncrypted
+	return nil
+}
@@ -0,0 +1,152 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/google/gopacket/layers"
+)
+
+// DefaultControlDomains 对照域名及其固定的解析结果
+var DefaultControlDomains = map[string][]string{
+	"one.one.one.one": {"1.1.1.1", "1.0.0.1"},
+	"dns.google":      {"8.8.8.8", "8.8.4.4"},
+}
+
+// DefaultNXZones 用于生成不存在域名的区域，这些区域没有泛解析
+var DefaultNXZones = []string{"google.com", "example.com", "cloudflare.com"}
+
+// ResolverCheckOptions 解析器检测配置
+type ResolverCheckOptions struct {
+	Resolvers []string            // 待检测的解析器
+	EtherInfo *device.EtherTable  // 网卡信息，udp 发包方式时可以为空
+	Transport string              // 发包方式: pcap(默认) 或 udp
+	Rate      int64               // 每秒发包速率
+	Timeout   time.Duration       // 每轮等待响应的时间
+	Retry     int                 // 未响应查询的重发次数
+	NXProbes  int                 // 每个解析器发送的不存在域名数量
+	Controls  map[string][]string // 对照域名及期望结果，为空时使用 DefaultControlDomains
+	NXZones   []string            // 生成不存在域名的区域，为空时使用 DefaultNXZones
+}
+
+// ResolverCheckResult 单个解析器的检测结果
+type ResolverCheckResult struct {
+	Resolver string
+	Valid    bool   // 是否可用
+	Reason   string // 不可用的原因
+}
+
+// probeKind 探测类型
+type probeKind int
+
+const (
+	probeNX      probeKind = iota // 不存在的域名
+	probeControl                  // 对照域名
+)
+
+// resolverProbe 发往某个解析器的探测查询
+type resolverProbe struct {
+	kind    probeKind
+	control string
+	reply   *probeReply
+}
+
+// CheckResolvers 使用原始发包引擎检测解析器：
+// 对随机的不存在域名返回解析结果的视为劫持，对照域名结果错误的视为投毒，无响应的视为失效
+func CheckResolvers(ctx context.Context, opt ResolverCheckOptions) ([]ResolverCheckResult, error) {
+	if len(opt.Resolvers) == 0 {
+		return nil, errors.New("解析器列表为空")
+	}
+	controls := opt.Controls
+	if len(controls) == 0 {
+		controls = DefaultControlDomains
+	}
+	zones := opt.NXZones
+	if len(zones) == 0 {
+		zones = DefaultNXZones
+	}
+	if opt.NXProbes <= 0 {
+		opt.NXProbes = 2
+	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = 3 * time.Second
+	}
+
+	// 为每个解析器生成探测请求，不存在的域名每次随机生成以避开缓存
+	probes := make(map[probeKey]*resolverProbe)
+	var queries []probeKey
+	add := func(key probeKey, p *resolverProbe) {
+		probes[key] = p
+		queries = append(queries, key)
+	}
+	for _, resolver := range opt.Resolvers {
+		for name := range controls {
+			add(probeKey{resolver, name, layers.DNSTypeA}, &resolverProbe{kind: probeControl, control: name})
+		}
+		for i := 0; i < opt.NXProbes; i++ {
+			name := core.RandomStr(12) + "." + zones[i%len(zones)]
+			add(probeKey{resolver, name, layers.DNSTypeA}, &resolverProbe{kind: probeNX})
+		}
+	}
+
+	prober, err := newRawProber(opt.Transport, opt.EtherInfo, opt.Rate)
+	if err != nil {
+		return nil, err
+	}
+	replies := prober.Exchange(ctx, queries, opt.Timeout, opt.Retry)
+	prober.Close()
+	for key, reply := range replies {
+		reply := reply
+		probes[key].reply = &reply
+	}
+
+	results := make([]ResolverCheckResult, 0, len(opt.Resolvers))
+	for _, resolver := range opt.Resolvers {
+		results = append(results, judgeResolver(resolver, probes, controls))
+	}
+	return results, ctx.Err()
+}
+
+// judgeResolver 根据探测结果判断解析器是否可用
+func judgeResolver(resolver string, probes map[probeKey]*resolverProbe, controls map[string][]string) ResolverCheckResult {
+	ret := ResolverCheckResult{Resolver: resolver}
+	for key, p := range probes {
+		if key.resolver != resolver {
+			continue
+		}
+		if p.reply == nil {
+			ret.Reason = fmt.Sprintf("无响应: %s", key.name)
+			return ret
+		}
+		var ips []string
+		for _, answer := range p.reply.answers {
+			if answer.Type == "A" {
+				ips = append(ips, answer.Value)
+			}
+		}
+		switch p.kind {
+		case probeNX:
+			if len(ips) > 0 {
+				ret.Reason = fmt.Sprintf("不存在的域名 %s 返回了 %v", key.name, ips)
+				return ret
+			}
+		case probeControl:
+			if p.reply.rcode != layers.DNSResponseCodeNoErr || len(ips) == 0 {
+				ret.Reason = fmt.Sprintf("对照域名 %s 解析失败", key.name)
+				return ret
+			}
+			for _, ip := range ips {
+				if !core.IsContain(controls[p.control], ip) {
+					ret.Reason = fmt.Sprintf("对照域名 %s 返回了错误结果 %s", key.name, ip)
+					return ret
+				}
+			}
+		}
+	}
+	ret.Valid = true
+	return ret
+}
@@ -0,0 +1,242 @@
+package resolverpool
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultMinSamples 评估健康度前至少需要发送的查询数
+	DefaultMinSamples = 50
+	// DefaultMinHealth 低于该健康度的解析器会被剔除
+	DefaultMinHealth = 0.3
+	// latencyBase 延迟惩罚的基准，平均延迟等于该值时权重减半
+	latencyBase = 200 * time.Millisecond
+)
+
+// resolver 单个解析器的统计信息，计数器均为原子操作
+type resolver struct {
+	server       string
+	sent         uint64
+	answered     uint64
+	timeouts     uint64
+	servfail     uint64
+	refused      uint64
+	totalLatency int64 // 纳秒
+	evicted      int32
+}
+
+// health 健康度：确定性响应(NOERROR/NXDOMAIN)占发送量的比例，加入平滑避免冷启动为0
+func (r *resolver) health() float64 {
+	sent := atomic.LoadUint64(&r.sent)
+	answered := atomic.LoadUint64(&r.answered)
+	return float64(answered+1) / float64(sent+2)
+}
+
+// avgLatency 平均响应延迟
+func (r *resolver) avgLatency() time.Duration {
+	answered := atomic.LoadUint64(&r.answered) + atomic.LoadUint64(&r.servfail) + atomic.LoadUint64(&r.refused)
+	if answered == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&r.totalLatency) / int64(answered))
+}
+
+// weight 选择权重，健康度越高、延迟越低权重越大
+func (r *resolver) weight() float64 {
+	latency := r.avgLatency()
+	return r.health() / (1 + float64(latency)/float64(latencyBase))
+}
+
+// Stats 解析器统计快照
+type Stats struct {
+	Server     string
+	Sent       uint64
+	Answered   uint64
+	Timeouts   uint64
+	ServFail   uint64
+	Refused    uint64
+	AvgLatency time.Duration
+	Health     float64
+	Evicted    bool
+}
+
+// Pool 按健康度加权选择解析器，并剔除长期异常的解析器
+type Pool struct {
+	resolvers  []*resolver
+	byServer   map[string]*resolver
+	minSamples uint64
+	minHealth  float64
+
+	mu         sync.RWMutex
+	active     []*resolver
+	cumulative []float64 // 权重前缀和，用于加权随机选择
+}
+
+// New 创建解析器池
+func New(servers []string) *Pool {
+	p := &Pool{
+		byServer:   make(map[string]*resolver),
+		minSamples: DefaultMinSamples,
+		minHealth:  DefaultMinHealth,
+	}
+	for _, server := range servers {
+		if _, ok := p.byServer[server]; ok {
+			continue
+		}
+		r := &resolver{server: server}
+		p.resolvers = append(p.resolvers, r)
+		p.byServer[server] = r
+	}
+	p.Rebalance()
+	return p
+}
+
+// SetEviction 设置剔除条件，minHealth为0时不剔除
+func (p *Pool) SetEviction(minSamples uint64, minHealth float64) {
+	p.minSamples = minSamples
+	p.minHealth = minHealth
+}
+
+// Select 按权重随机选择一个解析器
+func (p *Pool) Select() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.active) == 0 {
+		return ""
+	}
+	total := p.cumulative[len(p.cumulative)-1]
+	if total <= 0 {
+		return p.active[rand.Intn(len(p.active))].server
+	}
+	target := rand.Float64() * total
+	idx := sort.SearchFloat64s(p.cumulative, target)
+	if idx >= len(p.active) {
+		idx = len(p.active) - 1
+	}
+	return p.active[idx].server
+}
+
+// OnSent 记录一次发送
+func (p *Pool) OnSent(server string) {
+	if r, ok := p.byServer[server]; ok {
+		atomic.AddUint64(&r.sent, 1)
+	}
+}
+
+// OnAnswer 记录一次确定性响应(NOERROR/NXDOMAIN)
+func (p *Pool) OnAnswer(server string, latency time.Duration) {
+	if r, ok := p.byServer[server]; ok {
+		atomic.AddUint64(&r.answered, 1)
+		atomic.AddInt64(&r.totalLatency, int64(latency))
+	}
+}
+
+// OnServFail 记录一次SERVFAIL响应
+func (p *Pool) OnServFail(server string, latency time.Duration) {
+	if r, ok := p.byServer[server]; ok {
+		atomic.AddUint64(&r.servfail, 1)
+		atomic.AddInt64(&r.totalLatency, int64(latency))
+	}
+}
+
+// OnRefused 记录一次REFUSED响应
+func (p *Pool) OnRefused(server string, latency time.Duration) {
+	if r, ok := p.byServer[server]; ok {
+		atomic.AddUint64(&r.refused, 1)
+		atomic.AddInt64(&r.totalLatency, int64(latency))
+	}
+}
+
+// OnTimeout 记录一次超时
+func (p *Pool) OnTimeout(server string) {
+	if r, ok := p.byServer[server]; ok {
+		atomic.AddUint64(&r.timeouts, 1)
+	}
+}
+
+// Rebalance 重新计算选择权重，并剔除健康度过低的解析器，返回本次被剔除的解析器
+// 至少保留一个解析器，避免全部剔除后无法发送
+func (p *Pool) Rebalance() []string {
+	var evicted []string
+	var candidates []*resolver
+	for _, r := range p.resolvers {
+		if atomic.LoadInt32(&r.evicted) == 1 {
+			continue
+		}
+		candidates = append(candidates, r)
+	}
+
+	active := make([]*resolver, 0, len(candidates))
+	for _, r := range candidates {
+		if p.minHealth > 0 && atomic.LoadUint64(&r.sent) >= p.minSamples && r.health() < p.minHealth {
+			evicted = append(evicted, r.server)
+			continue
+		}
+		active = append(active, r)
+	}
+	// 全部不健康时保留健康度最高的一个
+	if len(active) == 0 && len(candidates) > 0 {
+		best := candidates[0]
+		for _, r := range candidates[1:] {
+			if r.health() > best.health() {
+				best = r
+			}
+		}
+		active = append(active, best)
+		for i, server := range evicted {
+			if server == best.server {
+				evicted = append(evicted[:i], evicted[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, server := range evicted {
+		atomic.StoreInt32(&p.byServer[server].evicted, 1)
+	}
+
+	cumulative := make([]float64, len(active))
+	var total float64
+	for i, r := range active {
+		total += r.weight()
+		cumulative[i] = total
+	}
+
+	p.mu.Lock()
+	p.active = active
+	p.cumulative = cumulative
+	p.mu.Unlock()
+	return evicted
+}
+
+// Len 返回当前可用的解析器数量
+func (p *Pool) Len() int {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.active)
+}
+
+// Stats 返回所有解析器的统计快照，按发送量降序
+func (p *Pool) Stats() []Stats {
+	stats := make([]Stats, 0, len(p.resolvers))
+	for _, r := range p.resolvers {
+		stats = append(stats, Stats{
+			Server:     r.server,
+			Sent:       atomic.LoadUint64(&r.sent),
+			Answered:   atomic.LoadUint64(&r.answered),
+			Timeouts:   atomic.LoadUint64(&r.timeouts),
+			ServFail:   atomic.LoadUint64(&r.servfail),
+			Refused:    atomic.LoadUint64(&r.refused),
+			AvgLatency: r.avgLatency(),
+			Health:     r.health(),
+			Evicted:    atomic.LoadInt32(&r.evicted) == 1,
+		})
+	}
+	sort.SliceStable(stats, func(i, j int) bool {
+		return stats[i].Sent > stats[j].Sent
+	})
+	return stats
+}
@@ -0,0 +1,40 @@
+package resolverpool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPoolEvictsUnhealthyResolver(t *testing.T) {
+	p := New([]string{"1.1.1.1", "8.8.8.8", "8.8.8.8"})
+	assert.Equal(t, 2, p.Len())
+
+	for i := 0; i < 100; i++ {
+		p.OnSent("1.1.1.1")
+		p.OnAnswer("1.1.1.1", 20*time.Millisecond)
+		p.OnSent("8.8.8.8")
+		p.OnTimeout("8.8.8.8")
+	}
+	evicted := p.Rebalance()
+	assert.Equal(t, []string{"8.8.8.8"}, evicted)
+	assert.Equal(t, 1, p.Len())
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, "1.1.1.1", p.Select())
+	}
+
+	stats := p.Stats()
+	assert.Len(t, stats, 2)
+	assert.True(t, stats[1].Evicted || stats[0].Evicted)
+}
+
+func TestPoolKeepsLastResolver(t *testing.T) {
+	p := New([]string{"1.1.1.1"})
+	for i := 0; i < 100; i++ {
+		p.OnSent("1.1.1.1")
+		p.OnTimeout("1.1.1.1")
+	}
+	assert.Empty(t, p.Rebalance())
+	assert.Equal(t, "1.1.1.1", p.Select())
+}
@@ -2,24 +2,38 @@ package runner
 
 import (
 	"context"
-	"fmt"
 	"sync"
+	"sync/atomic"
 
-	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
 )
 
-// handleResult 处理扫描结果
-func (r *Runner) handleResult(predictChan chan string) {
+// handleResultWithContext 处理扫描结果（带有context管理）
+func (r *Runner) handleResultWithContext(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
 	isWildCard := r.options.WildcardFilterMode != "none"
-	var wg sync.WaitGroup
-	var predictSignal bool = false
+	var wildcardReady chan struct{}
+	if r.wildcard != nil {
+		wildcardReady = r.wildcard.ready
+	}
 
-	for res := range r.resultChan {
+	// emit 过滤泛解析后输出结果
+	emit := func(res result.Result) {
+		// 断点中已输出的结果不再重复输出，其递归和预测候选已由 replaySeeds 重新生成
+		if _, ok := r.restored[restoredKey(res)]; ok {
+			return
+		}
 		// 过滤通配符域名
 		if isWildCard {
 			if checkWildIps(r.options.WildIps, res.Answers) {
-				continue
+				return
+			}
+		}
+		if r.wildcard != nil {
+			switch r.wildcard.check(ctx, res) {
+			case wildcardPending, wildcardMatched:
+				return
 			}
 		}
 
@@ -27,85 +41,49 @@ func (r *Runner) handleResult(predictChan chan string) {
 		for _, out := range r.options.Writer {
 			_ = out.WriteDomainResult(res)
 		}
+		if r.checkpoint != nil {
+			if err := r.checkpoint.AddResult(res); err != nil {
+				gologger.Warningf("记录断点结果失败: %v\n", err)
+			}
+		}
+
+		// 递归枚举下一级
+		r.recurse(ctx, res.Subdomain)
 
 		// 预测域名处理
-		if r.options.Predict {
-			wg.Add(1)
-			go func(domain string) {
-				defer wg.Done()
-				r.predict(res, predictChan)
-				if !predictSignal {
-					r.predictLoadDone <- struct{}{}
-					predictSignal = true
-				}
-			}(res.Subdomain)
-		}
+		r.predict(ctx, res.Subdomain)
 	}
-	wg.Wait()
-}
 
-// predict 根据已知域名预测新的子域名
-func (r *Runner) predict(res result.Result, predictChan chan string) error {
-	if r.domainChan == nil {
-		return fmt.Errorf("域名通道未初始化")
-	}
-	_, err := predict.PredictDomains(res.Subdomain, predictChan)
-	if err != nil {
-		return err
+	if atomic.LoadInt64(&r.replayPending) != 0 {
+		r.replaySeeds(ctx)
 	}
-	return nil
-}
-
-// handleResultWithContext 处理扫描结果（带有context管理）
-func (r *Runner) handleResultWithContext(ctx context.Context, wg *sync.WaitGroup, predictChan chan string) {
-	defer wg.Done()
-	isWildCard := r.options.WildcardFilterMode != "none"
-	var predictWg sync.WaitGroup
-	var predictSignal bool = false
 
 	for {
 		select {
 		case <-ctx.Done():
-			predictWg.Wait()
 			return
+		case <-wildcardReady:
+			// 区域探测完成，重新判断暂存的结果和等待中的递归种子
+			held := r.wildcard.takeHeld()
+			for _, res := range held {
+				emit(res)
+			}
+			r.wildcard.releaseHeld(len(held))
+			r.retryWaitingSeeds(ctx)
 		case res, ok := <-r.resultChan:
 			if !ok {
-				predictWg.Wait()
 				return
 			}
-			// 过滤通配符域名
-			if isWildCard {
-				if checkWildIps(r.options.WildIps, res.Answers) {
-					continue
-				}
-			}
-
-			// 将结果写入输出器
-			for _, out := range r.options.Writer {
-				_ = out.WriteDomainResult(res)
-			}
-
-			// 预测域名处理
-			if r.options.Predict {
-				predictWg.Add(1)
-				go func(domain string) {
-					defer predictWg.Done()
-					r.predict(res, predictChan)
-					if !predictSignal {
-						r.predictLoadDone <- struct{}{}
-						predictSignal = true
-					}
-				}(res.Subdomain)
-			}
+			emit(res)
 		}
 	}
 }
 
-// checkWildIps 检查是否为通配符IP
-func checkWildIps(wildIps []string, ip []string) bool {
+// checkWildIps 检查是否为用户指定的通配符IP
+func checkWildIps(wildIps []string, answers []result.Record) bool {
 	for _, w := range wildIps {
-		for _, i := range ip {
-			if w == i {
+		for _, answer := range answers {
+			if answer.IsIP() && w == answer.Value {
 				return true
 			}
 		}
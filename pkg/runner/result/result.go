@@ -1,6 +1,98 @@
 package result
 
+import "time"
+
 type Result struct {
-	Subdomain string   `json:"subdomain"`
-	Answers   []string `json:"answers"`
+	Subdomain string    `json:"subdomain"`
+	Type      string    `json:"type,omitempty"` // 产生该结果的查询类型
+	RCode     string    `json:"rcode"`          // 响应码，如 NOERROR
+	Timestamp time.Time `json:"timestamp"`      // 收到响应的时间
+	Answers   []Record  `json:"answers"`
+}
+
+// Record 单条解析记录
+type Record struct {
+	Type     string     `json:"type"`               // 记录类型，如 A、CNAME
+	Value    string     `json:"value"`              // 记录值，IP或目标域名等
+	TTL      uint32     `json:"ttl"`                // 生存时间
+	Resolver string     `json:"resolver,omitempty"` // 返回该记录的解析器
+	MX       *MXRecord  `json:"mx,omitempty"`
+	SOA      *SOARecord `json:"soa,omitempty"`
+	SRV      *SRVRecord `json:"srv,omitempty"`
+	CAA      *CAARecord `json:"caa,omitempty"`
+}
+
+// MXRecord 邮件交换记录
+type MXRecord struct {
+	Preference uint16 `json:"preference"`
+	Exchange   string `json:"exchange"`
+}
+
+// SOARecord 区域起始授权记录
+type SOARecord struct {
+	MName   string `json:"mname"`
+	RName   string `json:"rname"`
+	Serial  uint32 `json:"serial"`
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	Minimum uint32 `json:"minimum"`
+}
+
+// SRVRecord 服务定位记录
+type SRVRecord struct {
+	Priority uint16 `json:"priority"`
+	Weight   uint16 `json:"weight"`
+	Port     uint16 `json:"port"`
+	Target   string `json:"target"`
+}
+
+// CAARecord 证书颁发机构授权记录
+type CAARecord struct {
+	Flags uint8  `json:"flags"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// IsIP 判断是否为A/AAAA记录
+func (r Record) IsIP() bool {
+	return r.Type == "A" || r.Type == "AAAA"
+}
+
+// String 返回旧版的字符串形式，IP直接输出，其余类型带类型前缀，如 "CNAME x.com"
+func (r Record) String() string {
+	if r.IsIP() {
+		return r.Value
+	}
+	return r.Type + " " + r.Value
+}
+
+// LegacyAnswers 返回旧版 []string 形式的解析记录
+func (r Result) LegacyAnswers() []string {
+	answers := make([]string, 0, len(r.Answers))
+	for _, record := range r.Answers {
+		answers = append(answers, record.String())
+	}
+	return answers
+}
+
+// Summary 扫描结束时的统计信息
+type Summary struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Elapsed   float64   `json:"elapsed_seconds"`
+	Sent      uint64    `json:"sent"`
+	Received  uint64    `json:"received"`
+	Success   uint64    `json:"success"`
+	Failed    uint64    `json:"failed"`
+	NoError   uint64    `json:"noerror"`
+	NXDomain  uint64    `json:"nxdomain"`
+	ServFail  uint64    `json:"servfail"`
+	Refused   uint64    `json:"refused"`
+	Rejected  uint64    `json:"rejected"`  // 问题、DNS ID 或源端口不匹配而丢弃的响应
+	Truncated uint64    `json:"truncated"` // 设置了TC位、通过TCP重查的响应
+	Finished  bool      `json:"finished"`  // 是否正常扫描完毕，中断时为false
+
+	PredictSent uint64 `json:"predict_sent,omitempty"` // 预测模式投递的候选数量
+	PredictHits uint64 `json:"predict_hits,omitempty"` // 预测模式解析成功的候选数量
 }
@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+)
+
+// checkpointInterval 断点保存间隔
+const checkpointInterval = 10 * time.Second
+
+// restoreCheckpoint 从断点文件恢复生产者位置、待响应查询和已输出结果
+func (r *Runner) restoreCheckpoint() {
+	state := r.checkpoint.State()
+	if state.Position == 0 && len(state.Pending) == 0 && len(state.Results) == 0 {
+		return
+	}
+	r.skipCount = state.Position
+	r.sourcePosition = state.Position
+	for _, item := range state.Pending {
+		item.Time = time.Now()
+		r.statusDB.Add(statusdb.Key(item.Domain, item.QType), item)
+	}
+	r.restoredResults = state.Results
+	r.restored = make(map[string]struct{}, len(state.Results))
+	for _, res := range state.Results {
+		r.restored[restoredKey(res)] = struct{}{}
+	}
+	if len(state.Results) > 0 && (r.recursion != nil || r.predictor != nil) {
+		r.replayPending = 1
+	}
+	gologger.Infof("从断点恢复: 跳过已发送目标 %d 个, 待重发查询 %d 个, 已有结果 %d 条\n",
+		state.Position, len(state.Pending), len(state.Results))
+}
+
+// restoredKey 断点结果的去重键
+func restoredKey(res result.Result) string {
+	return res.Subdomain + "/" + res.Type
+}
+
+// replayResults 将断点中的结果重新写入输出器，保证输出文件完整；结果已在断点的结果文件中，不再重复记录
+func (r *Runner) replayResults() {
+	for _, res := range r.restoredResults {
+		for _, out := range r.options.Writer {
+			_ = out.WriteDomainResult(res)
+		}
+	}
+}
+
+// replaySeeds 将断点中的结果重新作为递归和预测的种子：断点只记录源域名位置，
+// 中断时已生成但尚未发送的候选没有保存，需要重新生成；只在结果处理协程中调用
+func (r *Runner) replaySeeds(ctx context.Context) {
+	defer atomic.StoreInt64(&r.replayPending, 0)
+	seeds := r.restoredResults
+	r.restoredResults = nil
+	if r.predictor != nil {
+		// 先记录全部已解析的域名，预测时不再把它们作为候选发送
+		for _, res := range seeds {
+			r.predictor.observe(res.Subdomain)
+		}
+	}
+	for _, res := range seeds {
+		r.recurse(ctx, res.Subdomain)
+		if r.predictor != nil {
+			r.predictSeed(ctx, res.Subdomain)
+		}
+	}
+}
+
+// resendPending 立即重发断点中尚未收到响应的查询
+func (r *Runner) resendPending(ctx context.Context) {
+	var pending []statusdb.Item
+	r.statusDB.Scan(func(key string, v statusdb.Item) error {
+		pending = append(pending, v)
+		return nil
+	})
+	for _, item := range pending {
+		select {
+		case r.retryChan <- item:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// checkpointLoop 定期保存扫描进度
+func (r *Runner) checkpointLoop(ctx context.Context) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			r.saveCheckpoint()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// saveCheckpoint 保存当前生产者位置与待响应查询
+func (r *Runner) saveCheckpoint() {
+	var pending []statusdb.Item
+	r.statusDB.Scan(func(key string, v statusdb.Item) error {
+		pending = append(pending, v)
+		return nil
+	})
+	// 等待泛解析判断的结果尚未输出，恢复后重新查询
+	if r.wildcard != nil {
+		pending = append(pending, r.wildcard.heldItems()...)
+	}
+	err := r.checkpoint.Save(atomic.LoadInt64(&r.sourcePosition), pending)
+	if err != nil {
+		gologger.Warningf("保存断点失败: %v\n", err)
+	}
+}
+
+// finishCheckpoint 扫描完成时删除断点文件，被中断时保存最终进度
+func (r *Runner) finishCheckpoint() {
+	if r.finished.Load() {
+		if err := r.checkpoint.Remove(); err != nil {
+			gologger.Warningf("删除断点文件失败: %v\n", err)
+		}
+		return
+	}
+	r.saveCheckpoint()
+	gologger.Infof("扫描已中断，进度已保存到 %s，使用相同的 --resume 参数可继续扫描\n", r.options.ResumeFile)
+}
@@ -0,0 +1,102 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// cancelOutput 收到第一条结果时取消扫描，模拟在递归候选发送前中断
+type cancelOutput struct {
+	collectOutput
+	cancel context.CancelFunc
+}
+
+func (c *cancelOutput) WriteDomainResult(r result.Result) error {
+	c.cancel()
+	return c.collectOutput.WriteDomainResult(r)
+}
+
+func TestResumeRecursive(t *testing.T) {
+	records := map[string]string{
+		"www.example.com.":      "1.1.1.1",
+		"dev.www.example.com.":  "2.2.2.2",
+		"test.www.example.com.": "3.3.3.3",
+	}
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveDNS(t, pc, func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		if ip, ok := records[req.Question[0].Name]; ok && req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP(ip),
+			})
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})
+	resumeFile := filepath.Join(t.TempDir(), "scan.resume")
+
+	run := func(ctx context.Context, out outputter.Output) *Runner {
+		domains := make(chan string, 1)
+		domains <- "www.example.com"
+		close(domains)
+		r, err := New(&options.Options{
+			Rate:               1000,
+			Domain:             domains,
+			Resolvers:          []string{pc.LocalAddr().String()},
+			Silent:             true,
+			TimeOut:            3,
+			Retry:              1,
+			Method:             options.EnumType,
+			Writer:             []outputter.Output{out},
+			WildcardFilterMode: "none",
+			Transport:          options.TransportUDP,
+			RootDomains:        []string{"example.com"},
+			Depth:              2,
+			DepthWords:         []string{"dev", "test"},
+			ResumeFile:         resumeFile,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.RunEnumeration(ctx)
+		r.Close()
+		return r
+	}
+
+	// 第一次扫描在 www.example.com 输出后中断，下一级候选尚未发送
+	ctx, cancel := context.WithCancel(context.Background())
+	first := &cancelOutput{cancel: cancel}
+	assert.False(t, run(ctx, first).finished.Load())
+	if assert.Len(t, first.results, 1) {
+		assert.Equal(t, "www.example.com", first.results[0].Subdomain)
+	}
+
+	// 继续扫描时已有结果重新作为递归种子，补齐中断时丢失的下一级
+	second := &collectOutput{}
+	assert.True(t, run(context.Background(), second).finished.Load())
+	var got []string
+	for _, res := range second.results {
+		got = append(got, res.Subdomain)
+	}
+	sort.Strings(got)
+	assert.Equal(t, []string{"dev.www.example.com", "test.www.example.com", "www.example.com"}, got)
+
+	_, err = os.Stat(resumeFile)
+	assert.True(t, os.IsNotExist(err))
+}
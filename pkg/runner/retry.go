@@ -9,6 +9,18 @@ import (
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
 )
 
+// retryExhausted 查询失败后是否已用完重试次数：--retry N 表示首次发送后最多重发N次，小于等于0时一直重试；
+// 超时和 SERVFAIL/REFUSED 重发使用同一判断
+func (r *Runner) retryExhausted(v statusdb.Item) bool {
+	return r.maxRetryCount > 0 && v.Retry >= r.maxRetryCount
+}
+
+// giveUp 放弃查询并计入失败
+func (r *Runner) giveUp(key string) {
+	r.statusDB.Del(key)
+	atomic.AddUint64(&r.failedCount, 1)
+}
+
 // retry 优化的重试机制
 // 使用超时检测和批量发送以提高效率
 func (r *Runner) retry(ctx context.Context) {
@@ -18,14 +30,14 @@ func (r *Runner) retry(ctx context.Context) {
 
 	// 用于批量发送的域名缓冲区
 	const batchSize = 100
-	retryDomains := make([]string, 0, batchSize)
+	retryDomains := make([]statusdb.Item, 0, batchSize)
 
 	// 记录上次扫描时间，当数据库为空时可以更节约资源
 	lastScanEmpty := false
 
 	// 启动多个worker用于处理重试
 	workerCount := 4
-	retryDomainCh := make(chan string, batchSize*2)
+	retryDomainCh := make(chan statusdb.Item, batchSize*2)
 	var wg sync.WaitGroup
 	wg.Add(workerCount)
 
@@ -37,12 +49,12 @@ func (r *Runner) retry(ctx context.Context) {
 				select {
 				case <-ctx.Done():
 					return
-				case domain, ok := <-retryDomainCh:
+				case item, ok := <-retryDomainCh:
 					if !ok {
 						return
 					}
 					// 重新发送
-					r.domainChan <- domain
+					r.retryChan <- item
 				}
 			}
 		}()
@@ -76,24 +88,25 @@ func (r *Runner) retry(ctx context.Context) {
 
 			// 收集需要重试的域名
 			r.statusDB.Scan(func(key string, v statusdb.Item) error {
-				// 超过最大重试次数则放弃
-				if r.maxRetryCount > 0 && v.Retry > r.maxRetryCount {
-					r.statusDB.Del(key)
-					atomic.AddUint64(&r.failedCount, 1)
-					return nil
-				}
-
 				// 检查是否超时
 				if int64(now.Sub(v.Time).Seconds()) >= r.timeoutSeconds {
+					r.resolverPool.OnTimeout(v.Dns)
+
+					// 超过最大重试次数则放弃
+					if r.retryExhausted(v) {
+						r.giveUp(key)
+						return nil
+					}
+
 					// 将域名添加到重试列表，或者使用批量发送通道
-					retryDomains = append(retryDomains, key)
+					retryDomains = append(retryDomains, v)
 
 					// 根据DNS服务器分组，以便批量发送
-					dns := r.selectDNSServer(key)
+					dns := r.selectDNSServer(v.Domain)
 					if _, ok := dnsBatches[dns]; !ok {
 						dnsBatches[dns] = make([]string, 0, batchSize)
 					}
-					dnsBatches[dns] = append(dnsBatches[dns], key)
+					dnsBatches[dns] = append(dnsBatches[dns], v.Domain)
 				}
 				return nil
 			})
@@ -104,14 +117,14 @@ func (r *Runner) retry(ctx context.Context) {
 			// 如果有需要重试的域名
 			if len(retryDomains) > 0 {
 				// 向工作协程发送重试域名
-				for _, domain := range retryDomains {
+				for _, item := range retryDomains {
 					// 非阻塞发送
 					select {
-					case retryDomainCh <- domain:
+					case retryDomainCh <- item:
 						// 发送成功
 					default:
 						// 通道满了，直接发送
-						r.domainChan <- domain
+						r.retryChan <- item
 					}
 				}
 			}
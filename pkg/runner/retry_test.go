@@ -0,0 +1,56 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryBudget(t *testing.T) {
+	// 超时和 SERVFAIL 使用同一重试次数：--retry 2 共发送3次后放弃
+	for name, reply := range map[string]bool{"timeout": false, "servfail": true} {
+		pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var received int32
+		serveDNS(t, pc, func(w dns.ResponseWriter, req *dns.Msg) {
+			atomic.AddInt32(&received, 1)
+			if !reply {
+				return
+			}
+			m := new(dns.Msg)
+			m.SetRcode(req, dns.RcodeServerFailure)
+			_ = w.WriteMsg(m)
+		})
+
+		domains := make(chan string, 1)
+		domains <- "a.example.com"
+		close(domains)
+		r, err := New(&options.Options{
+			Rate:               1000,
+			Domain:             domains,
+			Resolvers:          []string{pc.LocalAddr().String()},
+			Silent:             true,
+			TimeOut:            1,
+			Retry:              2,
+			Method:             options.VerifyType,
+			Writer:             []outputter.Output{&collectOutput{}},
+			WildcardFilterMode: "none",
+			Transport:          options.TransportUDP,
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		r.RunEnumeration(context.Background())
+		r.Close()
+		assert.Equal(t, int32(3), atomic.LoadInt32(&received), name)
+		assert.Equal(t, uint64(1), r.failedCount, name)
+	}
+}
@@ -5,42 +5,74 @@ import (
 	"math"
 	"math/rand"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/boy-hack/ksubdomain/v2/pkg/core"
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
-	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/predict"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/checkpoint"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/processbar"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/resolverpool"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+	"github.com/boy-hack/ksubdomain/v2/pkg/utils"
+	"github.com/google/gopacket/layers"
 	"github.com/google/gopacket/pcap"
-	"github.com/phayes/freeport"
 	"go.uber.org/ratelimit"
 )
 
 // Runner 表示子域名扫描的运行时结构
 type Runner struct {
-	statusDB        *statusdb.StatusDb // 状态数据库
-	options         *options.Options   // 配置选项
-	rateLimiter     ratelimit.Limiter  // 速率限制器
-	pcapHandle      *pcap.Handle       // 网络抓包句柄
-	successCount    uint64             // 成功数量
-	sendCount       uint64             // 发送数量
-	receiveCount    uint64             // 接收数量
-	failedCount     uint64             // 失败数量
-	domainChan      chan string        // 域名发送通道
-	resultChan      chan result.Result // 结果接收通道
-	listenPort      int                // 监听端口
-	dnsID           uint16             // DNS请求ID
-	maxRetryCount   int                // 最大重试次数
-	timeoutSeconds  int64              // 超时秒数
-	initialLoadDone chan struct{}      // 初始加载完成信号
-	predictLoadDone chan struct{}      // predict加载完成信号
-	startTime       time.Time          // 开始时间
-	stopSignal      chan struct{}      // 停止信号
+	statusDB         *statusdb.StatusDb     // 状态数据库
+	options          *options.Options       // 配置选项
+	rateLimiter      ratelimit.Limiter      // 速率限制器
+	transport        transport              // 查询的发送与响应接收
+	successCount     uint64                 // 成功数量
+	sendCount        uint64                 // 发送数量
+	receiveCount     uint64                 // 接收数量
+	failedCount      uint64                 // 失败数量
+	noErrorCount     uint64                 // NOERROR响应数量
+	nxdomainCount    uint64                 // NXDOMAIN响应数量
+	servfailCount    uint64                 // SERVFAIL响应数量
+	refusedCount     uint64                 // REFUSED响应数量
+	otherRcodeCount  uint64                 // 其他响应码数量
+	domainChan       chan string            // 域名发送通道
+	sourceChan       chan string            // 源域名发送通道
+	retryChan        chan statusdb.Item     // 重试发送通道
+	queryTypes       []layers.DNSType       // 每个域名需要查询的记录类型
+	resultChan       chan result.Result     // 结果接收通道
+	portIndex        uint64                 // 轮流选择源端口的计数
+	rejectedCount    uint64                 // 未通过校验而丢弃的响应数量
+	truncatedCount   uint64                 // 设置了TC位、通过TCP重查的响应数量
+	truncatedChan    chan truncatedQuery    // 等待TCP重查的截断响应
+	tcpPending       int64                  // 正在等待或进行TCP重查的查询数量
+	maxRetryCount    int                    // 最大重试次数
+	timeoutSeconds   int64                  // 超时秒数
+	initialLoadDone  chan struct{}          // 初始加载完成信号
+	startTime        time.Time              // 开始时间
+	stopSignal       chan struct{}          // 停止信号
+	sourcePosition   int64                  // 已发送的源域名数量
+	skipCount        int64                  // 断点恢复时跳过的源域名数量
+	checkpoint       *checkpoint.Checkpoint // 断点续扫状态
+	resolverPool     *resolverpool.Pool     // 解析器健康度统计与加权选择
+	restoredResults  []result.Result        // 断点中已输出的结果
+	restored         map[string]struct{}    // 断点中已输出的结果，按域名和查询类型去重，恢复后只读
+	replayPending    int64                  // 断点中的结果是否还未重新作为递归和预测的种子
+	wildcard         *wildcardDetector      // 按区域探测泛解析，为nil时不过滤
+	recursion        *recursion             // 递归枚举，为nil时不递归
+	recursivePending int64                  // 正在投递候选的递归种子数量
+	recursionWaiting int64                  // 等待泛解析探测的递归种子数量
+	predictor        *predictor             // 预测模式，为nil时不预测
+	predictPending   int64                  // 正在投递候选的预测种子数量
+	producers        sync.WaitGroup         // 向 domainChan 投递候选的协程，全部退出后才能关闭通道
+	authority        *authority             // 权威直连模式，为nil时查询递归解析器
+	finished         atomic.Bool            // 是否正常扫描完毕
 }
 
 func init() {
@@ -50,11 +82,48 @@ func init() {
 // New 创建一个新的Runner实例
 func New(opt *options.Options) (*Runner, error) {
 	var err error
-	version := pcap.Version()
 	r := new(Runner)
-	gologger.Infof(version)
+	if err = selectTransport(opt); err != nil {
+		return nil, err
+	}
+	if opt.Transport != options.TransportEncrypted {
+		if opt.Transport != options.TransportUDP {
+			gologger.Infof(pcap.Version())
+		}
+		if err = filterResolvers(opt); err != nil {
+			return nil, err
+		}
+	}
 	r.options = opt
 	r.statusDB = statusdb.CreateMemoryDB()
+	r.resolverPool = resolverpool.New(opt.Resolvers)
+	wildcardFilter, err := utils.NewWildcardFilter(opt.WildcardFilterMode)
+	if err != nil {
+		return nil, err
+	}
+	if wildcardFilter.ZoneProbe() {
+		r.wildcard = newWildcardDetector()
+	}
+	if opt.Depth > 1 && len(opt.RootDomains) > 0 {
+		r.recursion = newRecursion(opt.DepthWords)
+		gologger.Infof("递归枚举深度: %d, 字典: %d 条\n", opt.Depth, len(r.recursion.words))
+	}
+	if opt.Predict {
+		rules := opt.PredictRules
+		if rules == nil {
+			if rules, err = predict.DefaultRules(); err != nil {
+				return nil, err
+			}
+		}
+		r.predictor = newPredictor(rules, opt.PredictPerSeed, opt.PredictMax)
+		gologger.Infof("预测模式: 每个种子最多 %d 个候选, 总计最多 %d 个\n", r.predictor.perSeed, r.predictor.max)
+	}
+
+	if opt.Authoritative {
+		if err = r.initAuthority(); err != nil {
+			return nil, err
+		}
+	}
 
 	// 记录DNS服务器信息
 	gologger.Infof("默认DNS服务器: %s\n", core.SliceToString(opt.Resolvers))
@@ -66,12 +135,6 @@ func New(opt *options.Options) (*Runner, error) {
 		gologger.Infof("特殊DNS服务器: %s\n", core.SliceToString(keys))
 	}
 
-	// 初始化网络设备
-	r.pcapHandle, err = device.PcapInit(opt.EtherInfo.Device)
-	if err != nil {
-		return nil, err
-	}
-
 	// 设置速率限制
 	cpuLimit := float64(runtime.NumCPU() * 10000)
 	rateLimit := int(math.Min(cpuLimit, float64(opt.Rate)))
@@ -80,47 +143,90 @@ func New(opt *options.Options) (*Runner, error) {
 
 	// 初始化通道
 	r.domainChan = make(chan string, 50000)
+	r.retryChan = make(chan statusdb.Item, 50000)
+	r.sourceChan = make(chan string)
 	r.resultChan = make(chan result.Result, 5000)
 	r.stopSignal = make(chan struct{})
+	r.truncatedChan = make(chan truncatedQuery, tcpRetryQueue)
 
-	// 获取空闲端口
-	freePort, err := freeport.GetFreePort()
+	// 初始化发包方式，分配监听端口
+	r.transport, err = newTransport(opt.Transport, opt.EtherInfo, opt.SourcePorts, 10000)
 	if err != nil {
 		return nil, err
 	}
-	r.listenPort = freePort
-	gologger.Infof("监听端口: %d\n", freePort)
+	gologger.Infof("监听端口: %s\n", core.SliceToString(portStrings(r.transport.Ports())))
 
 	// 设置其他参数
-	r.dnsID = 0x2021 // ksubdomain的生日
 	r.maxRetryCount = opt.Retry
+	r.queryTypes = opt.QueryTypes
+	if len(r.queryTypes) == 0 {
+		r.queryTypes = []layers.DNSType{layers.DNSTypeA}
+	}
 	r.timeoutSeconds = int64(opt.TimeOut)
 	r.initialLoadDone = make(chan struct{})
-	r.predictLoadDone = make(chan struct{})
 	r.startTime = time.Now()
+
+	// 断点续扫
+	if opt.ResumeFile != "" {
+		r.checkpoint, err = checkpoint.Open(opt.ResumeFile)
+		if err != nil {
+			r.transport.Close()
+			return nil, err
+		}
+		r.restoreCheckpoint()
+	}
 	return r, nil
 }
 
+func portStrings(ports []uint16) []string {
+	s := make([]string, len(ports))
+	for i, port := range ports {
+		s[i] = strconv.Itoa(int(port))
+	}
+	return s
+}
+
 // selectDNSServer 根据域名智能选择DNS服务器
 func (r *Runner) selectDNSServer(domain string) string {
 	dnsServers := r.options.Resolvers
 	specialDNSServers := r.options.SpecialResolvers
 
+	// 权威直连模式下发给域名所在区域的权威服务器
+	if r.authority != nil {
+		if server := r.authority.server(domain); server != "" {
+			return server
+		}
+	}
+
 	// 根据域名后缀选择特定DNS服务器
 	if len(specialDNSServers) > 0 {
 		for suffix, servers := range specialDNSServers {
 			if strings.HasSuffix(domain, suffix) {
-				dnsServers = servers
-				break
+				idx := getRandomIndex() % len(servers)
+				return servers[idx]
 			}
 		}
 	}
 
+	// 按健康度加权选择默认DNS服务器
+	if server := r.resolverPool.Select(); server != "" {
+		return server
+	}
+
 	// 随机选择一个DNS服务器
 	idx := getRandomIndex() % len(dnsServers)
 	return dnsServers[idx]
 }
 
+// selectOtherDNSServer 重试时尽量选择与上次不同的DNS服务器
+func (r *Runner) selectOtherDNSServer(domain string, previous string) string {
+	server := r.selectDNSServer(domain)
+	for i := 0; i < 3 && server == previous; i++ {
+		server = r.selectDNSServer(domain)
+	}
+	return server
+}
+
 // getRandomIndex 获取随机索引
 func getRandomIndex() int {
 	return int(rand.Int31())
@@ -132,32 +238,48 @@ func (r *Runner) updateStatusBar() {
 		queueLength := r.statusDB.Length()
 		elapsedSeconds := int(time.Since(r.startTime).Seconds())
 		data := &processbar.ProcessData{
-			SuccessIndex: r.successCount,
-			SendIndex:    r.sendCount,
-			QueueLength:  queueLength,
-			RecvIndex:    r.receiveCount,
-			FaildIndex:   r.failedCount,
-			Elapsed:      elapsedSeconds,
+			SuccessIndex:    r.successCount,
+			SendIndex:       r.sendCount,
+			QueueLength:     queueLength,
+			RecvIndex:       r.receiveCount,
+			FaildIndex:      r.failedCount,
+			Elapsed:         elapsedSeconds,
+			NoErrorIndex:    atomic.LoadUint64(&r.noErrorCount),
+			NXDomainIndex:   atomic.LoadUint64(&r.nxdomainCount),
+			ServFailIndex:   atomic.LoadUint64(&r.servfailCount),
+			RefusedIndex:    atomic.LoadUint64(&r.refusedCount),
+			OtherRcodeIndex: atomic.LoadUint64(&r.otherRcodeCount),
 		}
 		r.options.ProcessBar.WriteData(data)
 	}
 }
 
 // loadDomainsFromSource 从源加载域名
-func (r *Runner) loadDomainsFromSource(wg *sync.WaitGroup) {
+func (r *Runner) loadDomainsFromSource(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
-	// 从域名源加载域名
+	// 从域名源加载域名，断点恢复时跳过已发送的部分
+	var skipped int64
 	for domain := range r.options.Domain {
-		r.domainChan <- domain
+		if skipped < r.skipCount {
+			skipped++
+			continue
+		}
+		select {
+		case r.sourceChan <- domain:
+		case <-ctx.Done():
+			return
+		}
 	}
 	// 通知初始加载完成
-	r.initialLoadDone <- struct{}{}
+	select {
+	case r.initialLoadDone <- struct{}{}:
+	case <-ctx.Done():
+	}
 }
 
 // monitorProgress 监控扫描进度
 func (r *Runner) monitorProgress(ctx context.Context, cancelFunc context.CancelFunc, wg *sync.WaitGroup) {
 	var initialLoadCompleted bool = false
-	var initialLoadPredict bool = false
 	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 	defer wg.Done()
@@ -166,12 +288,14 @@ func (r *Runner) monitorProgress(ctx context.Context, cancelFunc context.CancelF
 		case <-ticker.C:
 			// 更新状态栏
 			r.updateStatusBar()
+			// 根据健康度调整解析器权重
+			r.rebalanceResolvers()
 			// 检查是否完成
-			if initialLoadCompleted && initialLoadPredict {
-				queueLength := r.statusDB.Length()
-				if queueLength <= 0 {
+			if initialLoadCompleted {
+				if r.idle() {
 					gologger.Printf("\n")
 					gologger.Infof("扫描完毕")
+					r.finished.Store(true)
 					cancelFunc() // 使用传递的cancelFunc
 					return
 				}
@@ -180,25 +304,21 @@ func (r *Runner) monitorProgress(ctx context.Context, cancelFunc context.CancelF
 			// 初始加载完成后启动重试机制
 			go r.retry(ctx)
 			initialLoadCompleted = true
-		case <-r.predictLoadDone:
-			initialLoadPredict = true
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// processPredictedDomains 处理预测的域名
-func (r *Runner) processPredictedDomains(ctx context.Context, wg *sync.WaitGroup, predictChan chan string) {
-	defer wg.Done()
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case domain := <-predictChan:
-			r.domainChan <- domain
-		}
-	}
+// idle 是否已没有待响应的查询、待发送的域名和待处理的探测
+func (r *Runner) idle() bool {
+	return r.statusDB.Length() <= 0 && len(r.domainChan) == 0 &&
+		atomic.LoadInt64(&r.replayPending) == 0 &&
+		atomic.LoadInt64(&r.recursivePending) == 0 &&
+		atomic.LoadInt64(&r.recursionWaiting) == 0 &&
+		atomic.LoadInt64(&r.predictPending) == 0 &&
+		atomic.LoadInt64(&r.tcpPending) == 0 &&
+		(r.wildcard == nil || !r.wildcard.Busy())
 }
 
 // RunEnumeration 开始子域名枚举过程
@@ -207,50 +327,93 @@ func (r *Runner) RunEnumeration(ctx context.Context) {
 	ctx, cancelFunc := context.WithCancel(ctx)
 	defer cancelFunc()
 
-	// 创建等待组，现在需要等待5个goroutine（添加了sendCycle和handleResult）
+	// 等待组管理接收、发送、进度监控、结果处理和域名加载5个协程，递归/预测投递协程和权威服务器队列在其后单独等待
 	wg := &sync.WaitGroup{}
 	wg.Add(5)
 
+	// 断点恢复：重新输出已有结果，并定期保存进度
+	if r.checkpoint != nil {
+		r.replayResults()
+		go r.resendPending(ctx)
+		go r.checkpointLoop(ctx)
+	}
+
 	// 启动接收处理
 	go r.recvChanel(ctx, wg)
 
+	// 启动泛解析探测
+	if r.wildcard != nil {
+		go r.wildcardWorker(ctx)
+	}
+
 	// 启动发送处理（加入waitgroup管理）
 	go r.sendCycleWithContext(ctx, wg)
 
 	// 监控进度
 	go r.monitorProgress(ctx, cancelFunc, wg)
 
-	// 创建预测域名通道
-	predictChan := make(chan string, 1000)
-	if r.options.Predict {
-		wg.Add(1)
-		// 启动预测域名处理
-		go r.processPredictedDomains(ctx, wg, predictChan)
-	} else {
-		r.predictLoadDone <- struct{}{}
-	}
-
 	// 启动结果处理（加入waitgroup管理）
-	go r.handleResultWithContext(ctx, wg, predictChan)
+	go r.handleResultWithContext(ctx, wg)
 
 	// 从源加载域名
-	go r.loadDomainsFromSource(wg)
+	go r.loadDomainsFromSource(ctx, wg)
 
-	// 等待所有协程完成
+	// 等待所有协程完成，投递候选的协程都由结果处理协程启动，此后不会再有新的投递协程
 	wg.Wait()
+	cancelFunc()
+	r.producers.Wait()
+	if r.authority != nil {
+		r.authority.wg.Wait()
+	}
+
+	if r.checkpoint != nil {
+		r.finishCheckpoint()
+	}
 
-	// 关闭所有通道
-	close(predictChan)
 	// 安全关闭通道
 	close(r.resultChan)
 	close(r.domainChan)
 }
 
+// summary 汇总本次扫描的统计信息
+func (r *Runner) summary() result.Summary {
+	now := time.Now()
+	summary := result.Summary{
+		StartTime: r.startTime,
+		EndTime:   now,
+		Elapsed:   now.Sub(r.startTime).Seconds(),
+		Sent:      atomic.LoadUint64(&r.sendCount),
+		Received:  atomic.LoadUint64(&r.receiveCount),
+		Success:   atomic.LoadUint64(&r.successCount),
+		Failed:    atomic.LoadUint64(&r.failedCount),
+		NoError:   atomic.LoadUint64(&r.noErrorCount),
+		NXDomain:  atomic.LoadUint64(&r.nxdomainCount),
+		ServFail:  atomic.LoadUint64(&r.servfailCount),
+		Refused:   atomic.LoadUint64(&r.refusedCount),
+		Rejected:  atomic.LoadUint64(&r.rejectedCount),
+		Truncated: atomic.LoadUint64(&r.truncatedCount),
+		Finished:  r.finished.Load(),
+	}
+	if r.predictor != nil {
+		summary.PredictSent = r.predictor.Sent()
+		summary.PredictHits = r.predictor.Hits()
+	}
+	return summary
+}
+
 // Close 关闭Runner并释放资源
 func (r *Runner) Close() {
-	// 关闭网络抓包句柄
-	if r.pcapHandle != nil {
-		r.pcapHandle.Close()
+	// 输出解析器统计
+	r.printResolverStats()
+
+	// 输出预测统计
+	if r.predictor != nil {
+		gologger.Infof("预测候选: 发送 %d 个, 命中 %d 个\n", r.predictor.Sent(), r.predictor.Hits())
+	}
+
+	// 关闭发包与抓包
+	if r.transport != nil {
+		r.transport.Close()
 	}
 
 	// 关闭状态数据库
@@ -258,6 +421,23 @@ func (r *Runner) Close() {
 		r.statusDB.Close()
 	}
 
+	// 关闭断点的结果文件，扫描完成时已删除
+	if r.checkpoint != nil {
+		if err := r.checkpoint.Close(); err != nil {
+			gologger.Warningf("关闭断点结果文件失败: %v\n", err)
+		}
+	}
+
+	// 向支持汇总的输出器写入统计信息
+	summary := r.summary()
+	for _, out := range r.options.Writer {
+		if so, ok := out.(outputter.SummaryOutput); ok {
+			if err := so.WriteSummary(summary); err != nil {
+				gologger.Errorf("写入统计信息失败: %v", err)
+			}
+		}
+	}
+
 	// 关闭所有输出器
 	for _, out := range r.options.Writer {
 		err := out.Close()
@@ -16,7 +16,8 @@ import (
 func TestV(t *testing.T) {
 	for i := 0; i < 2; i++ {
 		domainChanel := make(chan string)
-		eth := options.GetDeviceConfig([]string{"114.114.114.114"})
+		eth, err := options.GetDeviceConfig([]string{"114.114.114.114"})
+		assert.NoError(t, err)
 		domains := []string{"stu.baidu.com", "www.baidu.com"}
 		go func() {
 			for _, d := range domains {
@@ -26,9 +27,9 @@ func TestV(t *testing.T) {
 		}()
 		w, _ := output.NewScreenOutput(true)
 		opt := &options.Options{
-			Rate:      options.Band2Rate("1m"),
+			Rate:      bandRate(t, "1m"),
 			Domain:    domainChanel,
-			Resolvers: options.GetResolvers(nil),
+			Resolvers: options.DefaultResolvers(),
 			Silent:    true,
 			TimeOut:   5,
 			Retry:     1,
@@ -61,9 +62,9 @@ func TestVerify(t *testing.T) {
 		close(domainChanel)
 	}()
 	opt := &options.Options{
-		Rate:      options.Band2Rate("1m"),
+		Rate:      bandRate(t, "1m"),
 		Domain:    domainChanel,
-		Resolvers: options.GetResolvers(nil),
+		Resolvers: options.DefaultResolvers(),
 		Silent:    false,
 		TimeOut:   5,
 		Retry:     1,
@@ -96,9 +97,9 @@ func TestEnum(t *testing.T) {
 	eth, err := device.AutoGetDevices(nil)
 	assert.NoError(t, err)
 	opt := &options.Options{
-		Rate:      options.Band2Rate("1m"),
+		Rate:      bandRate(t, "1m"),
 		Domain:    domainChanel,
-		Resolvers: options.GetResolvers(nil),
+		Resolvers: options.DefaultResolvers(),
 		Silent:    false,
 		TimeOut:   5,
 		Retry:     1,
@@ -134,9 +135,9 @@ func TestPredict(t *testing.T) {
 		close(domainChanel)
 	}()
 	opt := &options.Options{
-		Rate:      options.Band2Rate("1m"),
+		Rate:      bandRate(t, "1m"),
 		Domain:    domainChanel,
-		Resolvers: options.GetResolvers(nil),
+		Resolvers: options.DefaultResolvers(),
 		Silent:    false,
 		TimeOut:   5,
 		Retry:     1,
@@ -155,3 +156,9 @@ func TestPredict(t *testing.T) {
 	r.RunEnumeration(ctx)
 	r.Close()
 }
+
+func bandRate(t *testing.T, band string) int64 {
+	rate, err := options.Band2Rate(band)
+	assert.NoError(t, err)
+	return rate
+}
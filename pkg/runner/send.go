@@ -2,6 +2,7 @@ package runner
 
 import (
 	"context"
+	"math/rand"
 	"net"
 	"strings"
 	"sync"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
 	"github.com/boy-hack/ksubdomain/v2/pkg/device"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
 	"github.com/google/gopacket"
@@ -18,85 +20,84 @@ import (
 
 // packetTemplate DNS请求包模板
 type packetTemplate struct {
-	eth   *layers.Ethernet
-	ip    *layers.IPv4
+	link  gopacket.SerializableLayer // 链路层头，原始IP链路为nil
+	ip    gopacket.SerializableLayer // *layers.IPv4 或 *layers.IPv6
 	udp   *layers.UDP
 	opts  gopacket.SerializeOptions
-	buf   gopacket.SerializeBuffer
 	dnsip net.IP
 }
 
-func getOrCreate(dnsname string, ether *device.EtherTable, freeport uint16) *packetTemplate {
+// getOrCreate 按解析器地址族和链路封装方式创建请求包模板，网卡不支持该地址族或没有下一跳时返回nil
+func getOrCreate(dnsname string, ether *device.EtherTable, framing device.Framing, neighbors *device.Neighbors, freeport uint16) *packetTemplate {
 
 	// 创建新模板
-	DstIp := net.ParseIP(dnsname).To4()
-	eth := &layers.Ethernet{
-		SrcMAC:       ether.SrcMac.HardwareAddr(),
-		DstMAC:       ether.DstMac.HardwareAddr(),
-		EthernetType: layers.EthernetTypeIPv4,
-	}
-
-	ip := &layers.IPv4{
-		Version:    4,
-		IHL:        5,
-		TOS:        0,
-		Length:     0, // FIX
-		Id:         0,
-		Flags:      layers.IPv4DontFragment,
-		FragOffset: 0,
-		TTL:        255,
-		Protocol:   layers.IPProtocolUDP,
-		Checksum:   0,
-		SrcIP:      ether.SrcIp,
-		DstIP:      DstIp,
+	dstIP, dstPort := options.SplitResolver(dnsname)
+	if dstIP == nil {
+		return nil
 	}
-
 	udp := &layers.UDP{
 		SrcPort: layers.UDPPort(freeport),
-		DstPort: layers.UDPPort(53),
+		DstPort: layers.UDPPort(dstPort),
 	}
-
-	_ = udp.SetNetworkLayerForChecksum(ip)
-
 	template := &packetTemplate{
-		eth:   eth,
-		ip:    ip,
-		udp:   udp,
-		dnsip: DstIp,
+		udp: udp,
 		opts: gopacket.SerializeOptions{
 			ComputeChecksums: true,
 			FixLengths:       true,
 		},
-		buf: gopacket.NewSerializeBuffer(),
 	}
 
+	DstIp := dstIP.To4()
+	ipv6 := DstIp == nil
+	if (!ipv6 && !ether.SupportsIPv4()) || (ipv6 && !ether.SupportsIPv6()) {
+		return nil
+	}
+	link, ok := linkHeader(framing, ether, dstIP, neighbors)
+	if !ok {
+		return nil
+	}
+	template.link = link
+
+	if !ipv6 {
+		ip := &layers.IPv4{
+			Version:    4,
+			IHL:        5,
+			TOS:        0,
+			Length:     0, // FIX
+			Id:         0,
+			Flags:      layers.IPv4DontFragment,
+			FragOffset: 0,
+			TTL:        255,
+			Protocol:   layers.IPProtocolUDP,
+			Checksum:   0,
+			SrcIP:      ether.SrcIp,
+			DstIP:      DstIp,
+		}
+		_ = udp.SetNetworkLayerForChecksum(ip)
+		template.ip = ip
+		template.dnsip = DstIp
+		return template
+	}
+
+	ip6 := &layers.IPv6{
+		Version:    6,
+		HopLimit:   255,
+		NextHeader: layers.IPProtocolUDP,
+		SrcIP:      ether.SrcIp6,
+		DstIP:      dstIP,
+	}
+	_ = udp.SetNetworkLayerForChecksum(ip6)
+	template.ip = ip6
+	template.dnsip = dstIP
 	return template
 }
 
-// sendCycle 实现发送域名请求的循环
-func (r *Runner) sendCycle() {
-	// 从发送通道接收域名，分发给工作协程
-	for domain := range r.domainChan {
-		r.rateLimiter.Take()
-		v, ok := r.statusDB.Get(domain)
-		if !ok {
-			v = statusdb.Item{
-				Domain:      domain,
-				Dns:         r.selectDNSServer(domain),
-				Time:        time.Now(),
-				Retry:       0,
-				DomainLevel: 0,
-			}
-			r.statusDB.Add(domain, v)
-		} else {
-			v.Retry += 1
-			v.Time = time.Now()
-			v.Dns = r.selectDNSServer(domain)
-			r.statusDB.Set(domain, v)
-		}
-		send(domain, v.Dns, r.options.EtherInfo, r.dnsID, uint16(r.listenPort), r.pcapHandle, layers.DNSTypeA)
-		atomic.AddUint64(&r.sendCount, 1)
+// serialize 将模板各层和DNS层序列化到 buf
+func (t *packetTemplate) serialize(buf gopacket.SerializeBuffer, dns *layers.DNS) error {
+	if t.link == nil {
+		return gopacket.SerializeLayers(buf, t.opts, t.ip, t.udp, dns)
 	}
+	return gopacket.SerializeLayers(buf, t.opts, t.link, t.ip, t.udp, dns)
 }
 
 // sendCycleWithContext 实现带有context管理的发送域名请求循环
@@ -107,50 +108,131 @@ func (r *Runner) sendCycleWithContext(ctx context.Context, wg *sync.WaitGroup) {
 		select {
 		case <-ctx.Done():
 			return
+		case item := <-r.retryChan:
+			r.sendQuery(ctx, item.Domain, item.QType)
+		case domain := <-r.sourceChan:
+			r.sendDomain(ctx, domain)
+			atomic.AddInt64(&r.sourcePosition, 1)
 		case domain, ok := <-r.domainChan:
 			if !ok {
 				return
 			}
-			r.rateLimiter.Take()
-			v, ok := r.statusDB.Get(domain)
-			if !ok {
-				v = statusdb.Item{
-					Domain:      domain,
-					Dns:         r.selectDNSServer(domain),
-					Time:        time.Now(),
-					Retry:       0,
-					DomainLevel: 0,
-				}
-				r.statusDB.Add(domain, v)
-			} else {
-				v.Retry += 1
-				v.Time = time.Now()
-				v.Dns = r.selectDNSServer(domain)
-				r.statusDB.Set(domain, v)
-			}
-			send(domain, v.Dns, r.options.EtherInfo, r.dnsID, uint16(r.listenPort), r.pcapHandle, layers.DNSTypeA)
-			atomic.AddUint64(&r.sendCount, 1)
+			r.sendDomain(ctx, domain)
 		}
 	}
 }
 
-// send 发送单个DNS查询包
-func send(domain string, dnsname string, ether *device.EtherTable, dnsid uint16, freeport uint16, handle *pcap.Handle, dnsType layers.DNSType) {
+// sendDomain 按配置的每种记录类型发送一次查询
+func (r *Runner) sendDomain(ctx context.Context, domain string) {
+	for _, qtype := range r.queryTypes {
+		r.sendQuery(ctx, domain, qtype)
+	}
+}
+
+// sendQuery 发送单个(域名,类型)查询，并在状态数据库中登记或更新重试信息
+func (r *Runner) sendQuery(ctx context.Context, domain string, qtype layers.DNSType) {
+	r.rateLimiter.Take()
+	key := statusdb.Key(domain, qtype)
+	v, ok := r.statusDB.Get(key)
+	if !ok {
+		v = statusdb.Item{
+			Domain:      domain,
+			QType:       qtype,
+			Dns:         r.selectDNSServer(domain),
+			Retry:       0,
+			DomainLevel: 0,
+		}
+	} else {
+		v.Retry += 1
+		v.Dns = r.selectOtherDNSServer(domain, v.Dns)
+	}
+	// 直接查询权威服务器时不设置RD位，查询经该服务器的发送队列单独限速，避免压垮自建的小型NS
+	recursive := true
+	if r.authority != nil && r.authority.isAuthoritative(v.Dns) {
+		recursive = false
+		v.Dns = r.authority.pick(domain, v.Dns)
+	}
+	v.Time = time.Now()
+	// 每次发送使用新的DNS ID和源端口，迟到的旧响应和伪造的响应无法通过校验
+	v.ID = uint16(rand.Intn(0x10000))
+	v.Port = r.nextSourcePort()
+	if !ok {
+		r.statusDB.Add(key, v)
+	} else {
+		r.statusDB.Set(key, v)
+	}
+	send := func() {
+		r.transport.Send(domain, v.Dns, v.ID, v.Port, qtype, recursive)
+		r.resolverPool.OnSent(v.Dns)
+		atomic.AddUint64(&r.sendCount, 1)
+	}
+	if recursive {
+		send()
+		return
+	}
+	r.authority.enqueue(ctx, v.Dns, send)
+}
+
+// sender 组装并发送DNS查询包，每个扫描持有独立的发送句柄和内存池
+type sender struct {
+	ether     *device.EtherTable
+	handle    *pcap.Handle
+	framing   device.Framing
+	neighbors *device.Neighbors // 以太网链路上IPv6解析器的下一跳
+	pool      *MemoryPool
+}
+
+// newSender 按发送句柄的链路类型选择链路封装方式
+func newSender(ether *device.EtherTable, handle *pcap.Handle) (*sender, error) {
+	framing, err := device.LinkFraming(handle.LinkType())
+	if err != nil {
+		return nil, err
+	}
+	if framing == device.FramingSLL {
+		gologger.Warningf("网卡 %s 为 Linux cooked capture 链路，libpcap 可能不支持在该链路上发包\n", ether.Device)
+	}
+	s := &sender{
+		ether:   ether,
+		handle:  handle,
+		framing: framing,
+		pool:    NewMemoryPool(),
+	}
+	if framing == device.FramingEthernet && ether.SupportsIPv6() {
+		s.neighbors = device.NewNeighbors(ether)
+	}
+	return s, nil
+}
+
+// nextSourcePort 轮流使用订阅的监听端口作为源端口
+func (r *Runner) nextSourcePort() uint16 {
+	ports := r.transport.Ports()
+	if len(ports) == 1 {
+		return ports[0]
+	}
+	return ports[atomic.AddUint64(&r.portIndex, 1)%uint64(len(ports))]
+}
+
+// send 从源端口 srcPort 发送单个DNS查询包
+func (s *sender) send(domain string, dnsname string, dnsid uint16, srcPort uint16, dnsType layers.DNSType, recursive bool) {
 	// 复用DNS服务器的包模板
-	template := getOrCreate(dnsname, ether, freeport)
+	template := getOrCreate(dnsname, s.ether, s.framing, s.neighbors, srcPort)
+	if template == nil {
+		gologger.Debugf("网卡不支持解析器 %s 的地址族或没有下一跳，跳过\n", dnsname)
+		return
+	}
 
 	// 从内存池获取DNS层对象
-	dns := GlobalMemPool.GetDNS()
-	defer GlobalMemPool.PutDNS(dns)
+	dns := s.pool.GetDNS()
+	defer s.pool.PutDNS(dns)
 
 	// 设置DNS查询参数
 	dns.ID = dnsid
 	dns.QDCount = 1
-	dns.RD = true // 递归查询标识
+	dns.RD = recursive // 递归查询标识，直接查询权威服务器时不设置
 
 	// 从内存池获取questions切片
-	questions := GlobalMemPool.GetDNSQuestions()
-	defer GlobalMemPool.PutDNSQuestions(questions)
+	questions := s.pool.GetDNSQuestions()
+	defer s.pool.PutDNSQuestions(questions)
 
 	// 添加查询问题
 	questions = append(questions, layers.DNSQuestion{
@@ -161,22 +243,18 @@ func send(domain string, dnsname string, ether *device.EtherTable, dnsid uint16,
 	dns.Questions = questions
 
 	// 从内存池获取序列化缓冲区
-	buf := GlobalMemPool.GetBuffer()
-	defer GlobalMemPool.PutBuffer(buf)
+	buf := s.pool.GetBuffer()
+	defer s.pool.PutBuffer(buf)
 
 	// 序列化数据包
-	err := gopacket.SerializeLayers(
-		buf,
-		template.opts,
-		template.eth, template.ip, template.udp, dns,
-	)
+	err := template.serialize(buf, dns)
 	if err != nil {
 		gologger.Warningf("SerializeLayers faild:%s\n", err.Error())
 		return
 	}
 
 	// 发送数据包
-	err = handle.WritePacketData(buf.Bytes())
+	err = s.handle.WritePacketData(buf.Bytes())
 	if err == nil {
 		return
 	}
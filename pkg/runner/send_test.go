@@ -0,0 +1,90 @@
+package runner
+
+import (
+	"net"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetOrCreateIPv6(t *testing.T) {
+	ether := &device.EtherTable{
+		SrcIp:   net.ParseIP("192.168.1.2"),
+		SrcMac:  device.SelfMac{0, 1, 2, 3, 4, 5},
+		DstMac:  device.SelfMac{6, 7, 8, 9, 10, 11},
+		SrcIp6:  net.ParseIP("2001:db8::2"),
+		DstMac6: device.SelfMac{12, 13, 14, 15, 16, 17},
+	}
+	template := getOrCreate("[2606:4700:4700::1111]:5353", ether, device.FramingEthernet, nil, 40001)
+	assert.NotNil(t, template)
+
+	dns := &layers.DNS{ID: 0x1234, RD: true, QDCount: 1, Questions: []layers.DNSQuestion{
+		{Name: []byte("www.example.com"), Type: layers.DNSTypeAAAA, Class: layers.DNSClassIN},
+	}}
+	buf := gopacket.NewSerializeBuffer()
+	err := template.serialize(buf, dns)
+	assert.NoError(t, err)
+
+	packet := gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default)
+	eth := packet.Layer(layers.LayerTypeEthernet).(*layers.Ethernet)
+	assert.Equal(t, layers.EthernetTypeIPv6, eth.EthernetType)
+	assert.Equal(t, ether.DstMac6.HardwareAddr(), eth.DstMAC)
+	ip6 := packet.Layer(layers.LayerTypeIPv6).(*layers.IPv6)
+	assert.Equal(t, "2001:db8::2", ip6.SrcIP.String())
+	assert.Equal(t, "2606:4700:4700::1111", ip6.DstIP.String())
+	udp := packet.Layer(layers.LayerTypeUDP).(*layers.UDP)
+	assert.Equal(t, layers.UDPPort(40001), udp.SrcPort)
+	assert.Equal(t, layers.UDPPort(5353), udp.DstPort)
+	assert.Nil(t, packet.ErrorLayer())
+
+	// 只有IPv4的网卡不为IPv6解析器创建模板
+	ether.SrcIp6 = nil
+	assert.Nil(t, getOrCreate("2606:4700:4700::1111", ether, device.FramingEthernet, nil, 40001))
+	assert.NotNil(t, getOrCreate("1.1.1.1", ether, device.FramingEthernet, nil, 40001))
+}
+
+func TestLinkFramingRoundTrip(t *testing.T) {
+	// tun/WireGuard 等链路没有MAC地址
+	ether := &device.EtherTable{
+		Device: "wg0",
+		SrcIp:  net.ParseIP("10.0.0.2"),
+		SrcIp6: net.ParseIP("fd00::2"),
+	}
+	framings := []device.Framing{device.FramingRaw, device.FramingNull, device.FramingLoop, device.FramingSLL}
+	for _, framing := range framings {
+		for _, resolver := range []string{"1.1.1.1:5353", "[2606:4700:4700::1111]:5353"} {
+			template := getOrCreate(resolver, ether, framing, nil, 40001)
+			if !assert.NotNil(t, template, framing.String()) {
+				continue
+			}
+			dns := &layers.DNS{ID: 0x1234, QR: true, QDCount: 1, Questions: []layers.DNSQuestion{
+				{Name: []byte("www.example.com"), Type: layers.DNSTypeA, Class: layers.DNSClassIN},
+			}}
+			buf := gopacket.NewSerializeBuffer()
+			assert.NoError(t, template.serialize(buf, dns))
+
+			// 解码时响应的来源即本机地址，目的端口即模板中的解析器端口
+			resp, ok := newDecodingContext(framing).decode(buf.Bytes())
+			if !assert.True(t, ok, "%s %s", framing, resolver) {
+				continue
+			}
+			assert.Equal(t, uint16(5353), resp.port)
+			assert.Equal(t, uint16(0x1234), resp.dns.ID)
+			assert.Equal(t, "www.example.com", string(resp.dns.Questions[0].Name))
+		}
+	}
+	// 以太网链路没有下一跳MAC时无法发包
+	assert.Nil(t, getOrCreate("1.1.1.1", ether, device.FramingEthernet, nil, 40001))
+}
+
+func TestUsableResolvers(t *testing.T) {
+	resolvers := []string{"1.1.1.1", "2606:4700:4700::1111", "8.8.8.8:5353", "[2001:4860:4860::8888]:53"}
+	ether := &device.EtherTable{Device: "eth0", SrcIp: net.ParseIP("192.168.1.2")}
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8:5353"}, usableResolvers(resolvers, ether))
+
+	ether = &device.EtherTable{Device: "eth0", SrcIp6: net.ParseIP("2001:db8::2"), DstMac6: device.SelfMac{1, 2, 3, 4, 5, 6}}
+	assert.Equal(t, []string{"2606:4700:4700::1111", "[2001:4860:4860::8888]:53"}, usableResolvers(resolvers, ether))
+}
@@ -2,17 +2,28 @@ package statusdb
 
 import (
 	"hash/fnv"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/google/gopacket/layers"
 )
 
 type Item struct {
-	Domain      string    // 查询域名
-	Dns         string    // 查询dns
-	Time        time.Time // 发送时间
-	Retry       int       // 重试次数
-	DomainLevel int       // 域名层级
+	Domain      string         // 查询域名
+	QType       layers.DNSType // 查询类型
+	Dns         string         // 查询dns
+	Time        time.Time      // 发送时间
+	Retry       int            // 重试次数
+	DomainLevel int            // 域名层级
+	ID          uint16         // 最近一次发送的DNS ID
+	Port        uint16         // 最近一次发送的源端口
+}
+
+// Key 生成(域名,查询类型)对应的数据库键，同一域名的不同类型查询分别记录
+func Key(domain string, qtype layers.DNSType) string {
+	return domain + "|" + strconv.Itoa(int(qtype))
 }
 
 // StatusDb 使用分片锁实现的高性能状态数据库
@@ -151,6 +162,22 @@ func (r *StatusDb) Get(domain string) (Item, bool) {
 	return result, ok
 }
 
+// Claim 在分片锁内校验并认领一个项：match 返回true时作废该项记录的DNS ID和源端口后返回副本，
+// 同一次发送的重复响应无法再次认领；项仍保留在数据库中，由调用方删除或重发
+func (r *StatusDb) Claim(domain string, match func(Item) bool) (Item, bool) {
+	shard := r.getShard(domain)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	item, ok := shard.items[domain]
+	if !ok || !match(*item) {
+		return Item{}, false
+	}
+	result := *item
+	item.ID = 0
+	item.Port = 0
+	return result, true
+}
+
 // Length 获取元素总数
 func (r *StatusDb) Length() int64 {
 	return atomic.LoadInt64(&r.length)
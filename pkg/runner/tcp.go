@@ -0,0 +1,153 @@
+package runner
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/miekg/dns"
+)
+
+const (
+	tcpRetryWorkers = 8    // 通过TCP重新查询的并发数，每个协程持有一个TCP客户端
+	tcpRetryQueue   = 1000 // 等待TCP重查的截断响应数量
+)
+
+// truncatedQuery 设置了TC位的响应，等待通过TCP重新查询完整结果
+type truncatedQuery struct {
+	subdomain string
+	qtype     layers.DNSType
+	resolver  string
+	rcode     layers.DNSResponseCode
+	partial   []result.Record // UDP响应中已收到的部分记录
+}
+
+// tcpClient 通过TCP查询解析器，复用到同一解析器的连接
+type tcpClient struct {
+	client *dns.Client
+	conns  map[string]*dns.Conn
+}
+
+func newTCPClient(timeout time.Duration) *tcpClient {
+	return &tcpClient{
+		client: &dns.Client{Net: "tcp", Timeout: timeout},
+		conns:  make(map[string]*dns.Conn),
+	}
+}
+
+// exchange 发送查询，复用的连接已被解析器关闭时重新建立一次
+func (c *tcpClient) exchange(m *dns.Msg, resolver string) (*dns.Msg, error) {
+	ip, port := options.SplitResolver(resolver)
+	addr := net.JoinHostPort(ip.String(), strconv.Itoa(int(port)))
+	if conn, ok := c.conns[addr]; ok {
+		reply, _, err := c.client.ExchangeWithConn(m, conn)
+		if err == nil {
+			return reply, nil
+		}
+		conn.Close()
+		delete(c.conns, addr)
+	}
+	conn, err := c.client.Dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	reply, _, err := c.client.ExchangeWithConn(m, conn)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	c.conns[addr] = conn
+	return reply, nil
+}
+
+func (c *tcpClient) close() {
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// tcpRetryWorker 从截断队列中取出查询，通过TCP重新查询
+func (r *Runner) tcpRetryWorker(ctx context.Context) {
+	c := newTCPClient(time.Duration(r.timeoutSeconds) * time.Second)
+	defer c.close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-r.truncatedChan:
+			r.retryTruncated(ctx, c, q)
+		}
+	}
+}
+
+// retryTruncated 合并TCP查询到的完整记录，TCP查询失败时保留UDP响应中的部分记录
+func (r *Runner) retryTruncated(ctx context.Context, c *tcpClient, q truncatedQuery) {
+	defer atomic.AddInt64(&r.tcpPending, -1)
+
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(q.subdomain), uint16(q.qtype))
+	// 发给权威服务器的重查与UDP查询一样不设置RD位，并占用该服务器的发送配额
+	if r.authority != nil && r.authority.isAuthoritative(q.resolver) {
+		m.RecursionDesired = false
+		if !r.authority.limit(q.resolver).wait(ctx) {
+			return
+		}
+	}
+	answers, rcode := q.partial, q.rcode
+	reply, err := c.exchange(m, q.resolver)
+	if err != nil {
+		gologger.Debugf("TCP查询 %s 失败(%s): %s\n", q.subdomain, q.resolver, err.Error())
+	} else if records, tcpRcode, err := tcpRecords(reply, q.resolver); err != nil {
+		gologger.Debugf("解析TCP响应 %s 失败: %s\n", q.subdomain, err.Error())
+	} else if isFinalRcode(tcpRcode) {
+		answers, rcode = mergeRecords(answers, records), tcpRcode
+	}
+	if len(answers) == 0 {
+		return
+	}
+	atomic.AddUint64(&r.successCount, 1)
+	r.sendResult(ctx, result.Result{
+		Subdomain: q.subdomain,
+		Type:      options.QueryTypeName(q.qtype),
+		RCode:     rcodeName(rcode),
+		Timestamp: time.Now(),
+		Answers:   answers,
+	})
+}
+
+// tcpRecords 将 miekg/dns 的响应转换为结果记录，与UDP响应使用同一套转换
+func tcpRecords(reply *dns.Msg, resolver string) ([]result.Record, layers.DNSResponseCode, error) {
+	data, err := reply.Pack()
+	if err != nil {
+		return nil, 0, err
+	}
+	var msg layers.DNS
+	if err = msg.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		return nil, 0, err
+	}
+	return answerRecords(&msg, resolver), msg.ResponseCode, nil
+}
+
+// mergeRecords 合并两组记录，去掉重复的记录
+func mergeRecords(a, b []result.Record) []result.Record {
+	seen := make(map[string]bool, len(a)+len(b))
+	merged := make([]result.Record, 0, len(a)+len(b))
+	for _, list := range [][]result.Record{a, b} {
+		for _, record := range list {
+			k := record.Type + " " + record.Value
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			merged = append(merged, record)
+		}
+	}
+	return merged
+}
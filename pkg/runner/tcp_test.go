@@ -0,0 +1,131 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/google/gopacket/layers"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTruncatingResolver 在同一端口上监听UDP和TCP，UDP只返回第一条TXT记录并设置TC位，TCP返回全部记录
+func startTruncatingResolver(t *testing.T) string {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := net.ListenPacket("udp4", l.Addr().String())
+	if err != nil {
+		l.Close()
+		t.Fatal(err)
+	}
+	handler := dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		for i := 0; i < 3; i++ {
+			m.Answer = append(m.Answer, &dns.TXT{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeTXT, Class: dns.ClassINET, Ttl: 60},
+				Txt: []string{fmt.Sprintf("record-%d", i)},
+			})
+		}
+		if w.RemoteAddr().Network() == "udp" {
+			m.Answer = m.Answer[:1]
+			m.Truncated = true
+		}
+		_ = w.WriteMsg(m)
+	})
+	udp := &dns.Server{PacketConn: pc, Handler: handler}
+	tcp := &dns.Server{Listener: l, Handler: handler}
+	go func() { _ = udp.ActivateAndServe() }()
+	go func() { _ = tcp.ActivateAndServe() }()
+	t.Cleanup(func() {
+		_ = udp.Shutdown()
+		_ = tcp.Shutdown()
+	})
+	return l.Addr().String()
+}
+
+func TestTruncatedRetryAuthoritative(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var recursive int32
+	server := &dns.Server{Listener: l, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		if req.RecursionDesired {
+			atomic.AddInt32(&recursive, 1)
+		}
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Answer = append(m.Answer, testRR(t, req.Question[0].Name+" 60 IN A 1.1.1.1"))
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+
+	port := l.Addr().(*net.TCPAddr).Port
+	a := newAuthority([]string{"127.0.0.1"}, 100, uint16(port), nil)
+	z, _ := a.zone("example.com")
+	a.resolve(z, []string{"ns1"}, map[string][]net.IP{"ns1": {net.ParseIP("127.0.0.1")}})
+	resolver := l.Addr().String()
+	assert.True(t, a.isAuthoritative(resolver))
+
+	r := &Runner{authority: a, resultChan: make(chan result.Result, 1)}
+	c := newTCPClient(time.Second)
+	defer c.close()
+	r.retryTruncated(context.Background(), c, truncatedQuery{subdomain: "www.example.com", qtype: layers.DNSTypeA, resolver: resolver})
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&recursive))
+	assert.Len(t, r.resultChan, 1)
+	// TCP重查与UDP发送队列共用该服务器的配额
+	assert.Contains(t, a.limits, resolver)
+}
+
+func TestMergeRecords(t *testing.T) {
+	a := []result.Record{{Type: "TXT", Value: "x"}, {Type: "TXT", Value: "y"}}
+	b := []result.Record{{Type: "TXT", Value: "y"}, {Type: "TXT", Value: "z"}}
+	merged := mergeRecords(a, b)
+	assert.Len(t, merged, 3)
+	assert.Equal(t, "z", merged[2].Value)
+}
+
+func TestTruncatedTCPRetry(t *testing.T) {
+	resolver := startTruncatingResolver(t)
+	domains := make(chan string, 1)
+	domains <- "big.example.com"
+	close(domains)
+	out := &collectOutput{}
+	opt := &options.Options{
+		Rate:               1000,
+		Domain:             domains,
+		Resolvers:          []string{resolver},
+		Silent:             true,
+		TimeOut:            3,
+		Retry:              1,
+		Method:             options.VerifyType,
+		Writer:             []outputter.Output{out},
+		WildcardFilterMode: "none",
+		QueryTypes:         []layers.DNSType{layers.DNSTypeTXT},
+		Transport:          options.TransportUDP,
+	}
+	r, err := New(opt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	r.RunEnumeration(context.Background())
+	r.Close()
+
+	if assert.Len(t, out.results, 1) {
+		assert.Len(t, out.results[0].Answers, 3)
+		assert.Equal(t, resolver, out.results[0].Answers[2].Resolver)
+	}
+	assert.Equal(t, uint64(1), r.summary().Truncated)
+}
@@ -1,6 +1,7 @@
 package runner
 
 import (
+	"fmt"
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/device"
 	"github.com/phayes/freeport"
@@ -8,7 +9,8 @@ import (
 	"time"
 )
 
-func TestSpeed(ether *device.EtherTable) {
+// TestSpeed 向不可达的网关持续发包15秒，测试网卡的最大发送速度
+func TestSpeed(ether *device.EtherTable) error {
 	ether.DstMac = device.SelfMac(net.HardwareAddr{0x5c, 0xc9, 0x09, 0x33, 0x34, 0x80}) // 指定一个错误的dstmac地址，包会经过本机网卡，但是发不出去
 	var index int64 = 0
 	start := time.Now().UnixNano() / 1e6
@@ -16,18 +18,24 @@ func TestSpeed(ether *device.EtherTable) {
 	var dnsid uint16 = 0x2021
 	tmpFreeport, err := freeport.GetFreePort()
 	if err != nil {
-		gologger.Fatalf("freeport error:" + err.Error())
-		return
+		return fmt.Errorf("freeport error: %v", err)
 	}
 	handle, err := device.PcapInit(ether.Device)
+	if err != nil {
+		return err
+	}
 	defer handle.Close()
+	s, err := newSender(ether, handle)
 	if err != nil {
-		gologger.Fatalf("初始化pcap失败,error:" + err.Error())
-		return
+		return err
+	}
+	if s.framing != device.FramingEthernet {
+		// 非以太网链路无法用错误的网关MAC拦住数据包，测速包会真实发出
+		return fmt.Errorf("测速只支持以太网网卡，%s 的链路类型为 %s", ether.Device, s.framing)
 	}
 	var now int64
 	for {
-		send("www.hacking8.com", "1.1.1.2", ether, dnsid, uint16(tmpFreeport), handle, 1)
+		s.send("www.hacking8.com", "1.1.1.2", dnsid, uint16(tmpFreeport), 1, true)
 		index++
 		now = time.Now().UnixNano() / 1e6
 		tickTime := (now - start) / 1000
@@ -43,4 +51,5 @@ func TestSpeed(ether *device.EtherTable) {
 	tickTime := (now - start) / 1000
 	tickIndex := index / tickTime
 	gologger.Printf("\r %ds 总发送:%d Packet 平均每秒速度:%dpps\n", tickTime, index, tickIndex)
+	return nil
 }
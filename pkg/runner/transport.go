@@ -0,0 +1,91 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/device"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcap"
+)
+
+// transport 发送DNS查询并接收响应，扫描的其余部分(状态库、重试、结果)与具体实现无关
+// pcap 为原始发包，需要 root 或 CAP_NET_RAW 权限；udp 使用普通 socket，任何用户都可以运行；
+// encrypted 通过 DoT/DoH 查询，只用于验证模式
+type transport interface {
+	// Ports 查询可用的源端口，响应按目的端口校验
+	Ports() []uint16
+	// Send 从源端口 srcPort 向解析器 resolver 发送一个查询，recursive 为false时不设置RD位(直接查询权威服务器)
+	Send(domain string, resolver string, id uint16, srcPort uint16, qtype layers.DNSType, recursive bool)
+	// Responses 收到的DNS响应
+	Responses() <-chan dnsResponse
+	// Done 传输关闭后关闭，之后不再写入 Responses
+	Done() <-chan struct{}
+	Close()
+}
+
+// newTransport 按发包方式创建传输，ports 为源端口数量，buffer 为响应通道长度
+func newTransport(mode string, ether *device.EtherTable, ports int, buffer int) (transport, error) {
+	switch mode {
+	case "", options.TransportPcap:
+		return newPcapTransport(ether, ports, buffer)
+	case options.TransportUDP:
+		return newUDPTransport(ports, buffer)
+	case options.TransportEncrypted:
+		return newEncryptedTransport(buffer), nil
+	}
+	return nil, fmt.Errorf("未知的发包方式: %s", mode)
+}
+
+// pcapTransport 通过 pcap 句柄发送原始数据包，在共享抓包句柄上按端口接收响应
+type pcapTransport struct {
+	handle  *pcap.Handle
+	sender  *sender
+	capture *captureSub
+}
+
+func newPcapTransport(ether *device.EtherTable, ports int, buffer int) (*pcapTransport, error) {
+	if ether == nil {
+		return nil, errors.New("未指定网卡信息")
+	}
+	handle, err := device.PcapInit(ether.Device)
+	if err != nil {
+		return nil, err
+	}
+	sender, err := newSender(ether, handle)
+	if err != nil {
+		handle.Close()
+		return nil, err
+	}
+	capture, err := subscribeCapture(ether.Device, ports, buffer)
+	if err != nil {
+		handle.Close()
+		return nil, err
+	}
+	return &pcapTransport{handle: handle, sender: sender, capture: capture}, nil
+}
+
+func (t *pcapTransport) Ports() []uint16 {
+	return t.capture.ports
+}
+
+func (t *pcapTransport) Send(domain string, resolver string, id uint16, srcPort uint16, qtype layers.DNSType, recursive bool) {
+	t.sender.send(domain, resolver, id, srcPort, qtype, recursive)
+}
+
+func (t *pcapTransport) Responses() <-chan dnsResponse {
+	return t.capture.C
+}
+
+func (t *pcapTransport) Done() <-chan struct{} {
+	return t.capture.closed
+}
+
+// Close 取消抓包订阅，关闭发包句柄
+func (t *pcapTransport) Close() {
+	t.capture.Close()
+	if t.handle != nil {
+		t.handle.Close()
+	}
+}
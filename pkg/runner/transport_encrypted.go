@@ -0,0 +1,295 @@
+package runner
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+const (
+	encryptedPort       = 0    // 加密传输没有独立的源端口，所有查询使用同一个虚拟端口
+	dotConnsPerResolver = 2    // 每个DoT解析器的TLS连接数，每个连接上流水线发送查询
+	dohWorkers          = 16   // 每个DoH解析器同时进行的HTTP请求数，HTTP/2 下复用同一连接
+	encryptedQueueDepth = 1024 // 每个解析器待发送队列长度
+	dohMaxResponseSize  = 65535
+	encryptedTimeout    = 6 * time.Second // 建立连接和单个HTTP请求的超时
+)
+
+// encryptedTransport 通过 DNS-over-TLS 和 DNS-over-HTTPS 查询，查询内容不以明文离开本机
+// 每个解析器的客户端在首次发送时创建，响应仍按问题和DNS ID由扫描校验
+type encryptedTransport struct {
+	timeout time.Duration
+	rootCAs *x509.CertPool // 为nil时使用系统根证书
+	C       chan dnsResponse
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+
+	mu      sync.Mutex
+	clients map[string]chan []byte // 按解析器索引的发送队列
+	http    *http.Client
+}
+
+func newEncryptedTransport(buffer int) *encryptedTransport {
+	return &encryptedTransport{
+		timeout: encryptedTimeout,
+		C:       make(chan dnsResponse, buffer),
+		done:    make(chan struct{}),
+		clients: make(map[string]chan []byte),
+	}
+}
+
+func (t *encryptedTransport) Ports() []uint16 {
+	return []uint16{encryptedPort}
+}
+
+// Send 编码查询并放入解析器的发送队列
+func (t *encryptedTransport) Send(domain string, resolver string, id uint16, srcPort uint16, qtype layers.DNSType, recursive bool) {
+	queue := t.queue(resolver)
+	if queue == nil {
+		return
+	}
+	payload, err := packQuery(domain, id, qtype, recursive)
+	if err != nil {
+		gologger.Warningf("SerializeLayers faild:%s\n", err.Error())
+		return
+	}
+	select {
+	case queue <- payload:
+	case <-t.done:
+	}
+}
+
+// queue 返回解析器的发送队列，首次使用时启动对应的 DoT 或 DoH 客户端
+func (t *encryptedTransport) queue(resolver string) chan []byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if q, ok := t.clients[resolver]; ok {
+		return q
+	}
+	select {
+	case <-t.done:
+		return nil
+	default:
+	}
+	q := make(chan []byte, encryptedQueueDepth)
+	switch {
+	case strings.HasPrefix(resolver, options.SchemeDoH):
+		if t.http == nil {
+			t.http = t.newHTTPClient()
+		}
+		t.wg.Add(dohWorkers)
+		for i := 0; i < dohWorkers; i++ {
+			go t.dohWorker(resolver, q)
+		}
+	case strings.HasPrefix(resolver, options.SchemeDoT):
+		host, port := options.SplitTLSResolver(resolver)
+		addr := net.JoinHostPort(host, strconv.Itoa(int(port)))
+		t.wg.Add(dotConnsPerResolver)
+		for i := 0; i < dotConnsPerResolver; i++ {
+			go t.dotWorker(resolver, addr, host, q)
+		}
+	default:
+		gologger.Warningf("加密传输不支持明文解析器 %s\n", resolver)
+		return nil
+	}
+	t.clients[resolver] = q
+	return q
+}
+
+// dotWorker 维护一个到DoT解析器的TLS连接，查询连续写入而不等待响应，响应由读协程按到达顺序交给扫描
+// 连接断开后在下一个查询到来时重连，断开时未收到响应的查询由扫描的超时重试处理
+func (t *encryptedTransport) dotWorker(resolver string, addr string, serverName string, queue chan []byte) {
+	defer t.wg.Done()
+	var conn net.Conn
+	var readerDone chan struct{}
+	closeConn := func() {
+		if conn != nil {
+			conn.Close()
+			conn, readerDone = nil, nil
+		}
+	}
+	defer closeConn()
+
+	frame := make([]byte, 0, 512)
+	for {
+		var msg []byte
+		select {
+		case msg = <-queue:
+		case <-readerDone:
+			closeConn()
+			continue
+		case <-t.done:
+			return
+		}
+		if conn == nil {
+			var err error
+			conn, err = t.dialTLS(addr, serverName)
+			if err != nil {
+				gologger.Debugf("连接DoT解析器 %s 失败: %s\n", resolver, err.Error())
+				continue
+			}
+			readerDone = make(chan struct{})
+			t.wg.Add(1)
+			go t.dotReader(resolver, conn, readerDone)
+		}
+		frame = binary.BigEndian.AppendUint16(frame[:0], uint16(len(msg)))
+		frame = append(frame, msg...)
+		_ = conn.SetWriteDeadline(time.Now().Add(t.timeout))
+		if _, err := conn.Write(frame); err != nil {
+			gologger.Debugf("发送到DoT解析器 %s 失败: %s\n", resolver, err.Error())
+			closeConn()
+		}
+	}
+}
+
+func (t *encryptedTransport) dialTLS(addr string, serverName string) (net.Conn, error) {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: t.timeout},
+		Config: &tls.Config{
+			ServerName: serverName,
+			RootCAs:    t.rootCAs,
+			MinVersion: tls.VersionTLS12,
+			NextProtos: []string{"dot"},
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// dotReader 读取长度前缀的响应，连接出错或关闭时退出
+func (t *encryptedTransport) dotReader(resolver string, conn net.Conn, done chan struct{}) {
+	defer t.wg.Done()
+	defer close(done)
+	var length [2]byte
+	for {
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+		if !t.emit(resolver, data) {
+			return
+		}
+	}
+}
+
+func (t *encryptedTransport) newHTTPClient() *http.Client {
+	return &http.Client{
+		Timeout: t.timeout,
+		Transport: &http.Transport{
+			Proxy:               http.ProxyFromEnvironment,
+			TLSClientConfig:     &tls.Config{RootCAs: t.rootCAs, MinVersion: tls.VersionTLS12},
+			ForceAttemptHTTP2:   true,
+			MaxConnsPerHost:     2,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: t.timeout,
+		},
+	}
+}
+
+// dohWorker 以 RFC 8484 的 POST 方式发送查询，多个协程共享 HTTP/2 连接
+func (t *encryptedTransport) dohWorker(resolver string, queue chan []byte) {
+	defer t.wg.Done()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-t.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	for {
+		var msg []byte
+		select {
+		case msg = <-queue:
+		case <-t.done:
+			return
+		}
+		data, err := t.doh(ctx, resolver, msg)
+		if err != nil {
+			gologger.Debugf("DoH查询 %s 失败: %s\n", resolver, err.Error())
+			continue
+		}
+		if !t.emit(resolver, data) {
+			return
+		}
+	}
+}
+
+func (t *encryptedTransport) doh(ctx context.Context, resolver string, msg []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, resolver, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+	resp, err := t.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, dohMaxResponseSize))
+}
+
+// emit 解码响应并写入响应通道，传输关闭时返回false
+func (t *encryptedTransport) emit(resolver string, data []byte) bool {
+	resp := dnsResponse{resolver: resolver, port: encryptedPort}
+	if err := resp.dns.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+		return true
+	}
+	if !resp.dns.QR || len(resp.dns.Questions) == 0 {
+		return true
+	}
+	select {
+	case t.C <- resp:
+		return true
+	case <-t.done:
+		return false
+	}
+}
+
+func (t *encryptedTransport) Responses() <-chan dnsResponse {
+	return t.C
+}
+
+func (t *encryptedTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+// Close 关闭所有连接并等待客户端协程退出
+func (t *encryptedTransport) Close() {
+	t.once.Do(func() {
+		t.mu.Lock()
+		close(t.done)
+		t.mu.Unlock()
+		t.wg.Wait()
+		if t.http != nil {
+			t.http.CloseIdleConnections()
+		}
+	})
+}
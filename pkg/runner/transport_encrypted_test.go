@@ -0,0 +1,203 @@
+package runner
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/google/gopacket/layers"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+)
+
+// testAnswer a.example.com 解析为 1.2.3.4，其余返回 NXDOMAIN，与 startTestResolver 一致
+func testAnswer(req *dns.Msg) *dns.Msg {
+	m := new(dns.Msg)
+	m.SetReply(req)
+	if req.Question[0].Name == "a.example.com." && req.Question[0].Qtype == dns.TypeA {
+		m.Answer = append(m.Answer, &dns.A{
+			Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+			A:   net.ParseIP("1.2.3.4"),
+		})
+	} else {
+		m.Rcode = dns.RcodeNameError
+	}
+	return m
+}
+
+// startTestDoH 启动本机DoH服务，返回解析器地址和信任其证书的根证书池
+func startTestDoH(t *testing.T) (string, *x509.CertPool) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+		msg := new(dns.Msg)
+		if err != nil || req.Method != http.MethodPost || msg.Unpack(body) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		data, _ := testAnswer(msg).Pack()
+		w.Header().Set("Content-Type", "application/dns-message")
+		_, _ = w.Write(data)
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	return server.URL + "/dns-query", pool
+}
+
+// countListener 统计建立的连接数
+type countListener struct {
+	net.Listener
+	accepted int32
+}
+
+func (l *countListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		atomic.AddInt32(&l.accepted, 1)
+	}
+	return conn, err
+}
+
+// startTestDoT 启动本机DoT服务，证书借用 httptest 生成的 127.0.0.1 证书
+func startTestDoT(t *testing.T) (string, *x509.CertPool, *countListener) {
+	cert := httptest.NewUnstartedServer(nil)
+	cert.StartTLS()
+	cert.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Certificate())
+
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter := &countListener{Listener: l}
+	server := &dns.Server{
+		Net:      "tcp-tls",
+		Listener: tls.NewListener(counter, &tls.Config{Certificates: cert.TLS.Certificates}),
+		Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+			_ = w.WriteMsg(testAnswer(req))
+		}),
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	resolver, err := options.ParseResolver("tls://" + l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resolver, pool, counter
+}
+
+func TestEncryptedTransportDoT(t *testing.T) {
+	resolver, pool, counter := startTestDoT(t)
+	tr := newEncryptedTransport(64)
+	tr.rootCAs = pool
+	defer tr.Close()
+
+	const queries = 20
+	for i := 0; i < queries; i++ {
+		tr.Send("a.example.com", resolver, uint16(i), encryptedPort, layers.DNSTypeA, true)
+	}
+	ids := make(map[uint16]bool)
+	for len(ids) < queries {
+		select {
+		case resp := <-tr.Responses():
+			assert.Equal(t, resolver, resp.resolver)
+			assert.Equal(t, uint16(encryptedPort), resp.port)
+			assert.Equal(t, "1.2.3.4", resp.dns.Answers[0].IP.String())
+			ids[resp.dns.ID] = true
+		case <-time.After(5 * time.Second):
+			t.Fatalf("只收到 %d 个响应", len(ids))
+		}
+	}
+	// 查询在少量连接上流水线发送
+	assert.LessOrEqual(t, atomic.LoadInt32(&counter.accepted), int32(dotConnsPerResolver))
+}
+
+func TestEncryptedTransportDoH(t *testing.T) {
+	resolver, pool := startTestDoH(t)
+	tr := newEncryptedTransport(16)
+	tr.rootCAs = pool
+	defer tr.Close()
+
+	tr.Send("b.example.com", resolver, 0x4321, encryptedPort, layers.DNSTypeA, true)
+	select {
+	case resp := <-tr.Responses():
+		assert.Equal(t, resolver, resp.resolver)
+		assert.Equal(t, uint16(0x4321), resp.dns.ID)
+		assert.Equal(t, layers.DNSResponseCodeNXDomain, resp.dns.ResponseCode)
+	case <-time.After(5 * time.Second):
+		t.Fatal("没有收到响应")
+	}
+}
+
+func TestSelectTransport(t *testing.T) {
+	opt := &options.Options{Method: options.VerifyType, Resolvers: []string{"tls://1.1.1.1"}}
+	assert.NoError(t, selectTransport(opt))
+	assert.Equal(t, options.TransportEncrypted, opt.Transport)
+
+	opt = &options.Options{
+		Method:           options.VerifyType,
+		Resolvers:        []string{"tls://1.1.1.1"},
+		SpecialResolvers: map[string][]string{"example.com": {"8.8.8.8"}},
+	}
+	assert.Error(t, selectTransport(opt))
+
+	opt = &options.Options{Method: options.EnumType, Resolvers: []string{"https://dns.google/dns-query"}}
+	assert.Error(t, selectTransport(opt))
+
+	opt = &options.Options{Method: options.EnumType, Resolvers: []string{"8.8.8.8"}, Transport: options.TransportUDP}
+	assert.NoError(t, selectTransport(opt))
+	assert.Equal(t, options.TransportUDP, opt.Transport)
+}
+
+func TestRunnerEncryptedTransport(t *testing.T) {
+	resolver, pool := startTestDoH(t)
+	domains := make(chan string)
+	go func() {
+		for _, d := range []string{"a.example.com", "b.example.com"} {
+			domains <- d
+		}
+		close(domains)
+	}()
+	out := &collectOutput{}
+	opt := &options.Options{
+		Rate:               1000,
+		Domain:             domains,
+		Resolvers:          []string{resolver},
+		Silent:             true,
+		TimeOut:            3,
+		Retry:              1,
+		Method:             options.VerifyType,
+		Writer:             []outputter.Output{out},
+		WildcardFilterMode: "none",
+	}
+	r, err := New(opt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	// 换成信任测试证书的传输
+	r.transport.Close()
+	tr := newEncryptedTransport(10000)
+	tr.rootCAs = pool
+	r.transport = tr
+
+	r.RunEnumeration(context.Background())
+	r.Close()
+
+	if assert.Len(t, out.results, 1) {
+		assert.Equal(t, "a.example.com", out.results[0].Subdomain)
+		assert.Equal(t, resolver, out.results[0].Answers[0].Resolver)
+	}
+	assert.Equal(t, uint64(2), r.receiveCount)
+}
@@ -0,0 +1,280 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	udpBatchSize  = 64      // 每次 sendmmsg/recvmmsg 最多处理的报文数
+	udpReadSize   = 4096    // 单个响应的接收缓冲区大小
+	udpSocketBuf  = 4 << 20 // socket 收发缓冲区大小
+	udpQueueDepth = 4096    // 每个 socket 待发送队列长度
+
+	udpReadBackoffMin = time.Millisecond // 读取出错后的初始等待时间
+	udpReadBackoffMax = time.Second      // 连续读取出错时的最长等待时间
+)
+
+// batchConn ipv4.PacketConn 和 ipv6.PacketConn 的批量收发接口，
+// 两者的 Message 都是 socket.Message 的别名；非 Linux 系统上退化为逐个收发
+type batchConn interface {
+	ReadBatch(ms []ipv4.Message, flags int) (int, error)
+	WriteBatch(ms []ipv4.Message, flags int) (int, error)
+}
+
+// udpSocket 一个源端口上某个地址族的 socket
+type udpSocket struct {
+	conn  *net.UDPConn
+	batch batchConn
+	queue chan ipv4.Message
+	port  uint16
+}
+
+// udpTransport 使用普通UDP socket收发查询，不需要 root 或 CAP_NET_RAW 权限
+// 每个源端口有一个IPv4 socket，并尽量在同一端口上打开IPv6 socket
+type udpTransport struct {
+	ports []uint16
+	v4    map[uint16]*udpSocket
+	v6    map[uint16]*udpSocket
+	C     chan dnsResponse
+	done  chan struct{}
+	once  sync.Once
+	wg    sync.WaitGroup
+}
+
+func newUDPTransport(ports int, buffer int) (*udpTransport, error) {
+	if ports < 1 {
+		ports = 1
+	}
+	t := &udpTransport{
+		v4:   make(map[uint16]*udpSocket, ports),
+		v6:   make(map[uint16]*udpSocket, ports),
+		C:    make(chan dnsResponse, buffer),
+		done: make(chan struct{}),
+	}
+	var v6Err error
+	for i := 0; i < ports; i++ {
+		conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+		if err != nil {
+			t.closeSockets()
+			return nil, fmt.Errorf("分配监听端口失败: %v", err)
+		}
+		port := uint16(conn.LocalAddr().(*net.UDPAddr).Port)
+		t.ports = append(t.ports, port)
+		t.v4[port] = newUDPSocket(conn, ipv4.NewPacketConn(conn), port)
+
+		// IPv6 使用相同的端口号，以便按端口校验响应
+		conn6, err := net.ListenUDP("udp6", &net.UDPAddr{Port: int(port)})
+		if err != nil {
+			v6Err = err
+			continue
+		}
+		t.v6[port] = newUDPSocket(conn6, ipv6.NewPacketConn(conn6), port)
+	}
+	if v6Err != nil {
+		gologger.Warningf("部分源端口无法使用IPv6，这些端口不会向IPv6解析器发送查询: %v\n", v6Err)
+	}
+
+	for _, socks := range []map[uint16]*udpSocket{t.v4, t.v6} {
+		for _, s := range socks {
+			t.wg.Add(2)
+			go t.writeLoop(s)
+			go t.readLoop(s)
+		}
+	}
+	return t, nil
+}
+
+func newUDPSocket(conn *net.UDPConn, batch batchConn, port uint16) *udpSocket {
+	_ = conn.SetReadBuffer(udpSocketBuf)
+	_ = conn.SetWriteBuffer(udpSocketBuf)
+	return &udpSocket{
+		conn:  conn,
+		batch: batch,
+		queue: make(chan ipv4.Message, udpQueueDepth),
+		port:  port,
+	}
+}
+
+func (t *udpTransport) Ports() []uint16 {
+	return t.ports
+}
+
+// Send 编码查询并放入对应 socket 的发送队列，由发送协程批量发出
+func (t *udpTransport) Send(domain string, resolver string, id uint16, srcPort uint16, qtype layers.DNSType, recursive bool) {
+	ip, port := options.SplitResolver(resolver)
+	if ip == nil {
+		return
+	}
+	s := t.v4[srcPort]
+	if ip.To4() == nil {
+		s = t.v6[srcPort]
+	}
+	if s == nil {
+		gologger.Debugf("源端口 %d 不支持解析器 %s 的地址族，跳过\n", srcPort, resolver)
+		return
+	}
+	payload, err := packQuery(domain, id, qtype, recursive)
+	if err != nil {
+		gologger.Warningf("SerializeLayers faild:%s\n", err.Error())
+		return
+	}
+	msg := ipv4.Message{
+		Buffers: [][]byte{payload},
+		Addr:    &net.UDPAddr{IP: ip, Port: int(port)},
+	}
+	select {
+	case s.queue <- msg:
+	case <-t.done:
+	}
+}
+
+// packQuery 编码一个查询，recursive 为true时设置RD位
+func packQuery(domain string, id uint16, qtype layers.DNSType, recursive bool) ([]byte, error) {
+	dns := &layers.DNS{
+		ID:      id,
+		QDCount: 1,
+		RD:      recursive,
+		Questions: []layers.DNSQuestion{{
+			Name:  []byte(domain),
+			Type:  qtype,
+			Class: layers.DNSClassIN,
+		}},
+	}
+	buf := gopacket.NewSerializeBuffer()
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, dns)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeLoop 合并队列中已有的查询，一次系统调用批量发送
+func (t *udpTransport) writeLoop(s *udpSocket) {
+	defer t.wg.Done()
+	batch := make([]ipv4.Message, 0, udpBatchSize)
+	for {
+		select {
+		case msg := <-s.queue:
+			batch = append(batch[:0], msg)
+		case <-t.done:
+			return
+		}
+	drain:
+		for len(batch) < udpBatchSize {
+			select {
+			case msg := <-s.queue:
+				batch = append(batch, msg)
+			default:
+				break drain
+			}
+		}
+		for len(batch) > 0 {
+			n, err := s.batch.WriteBatch(batch, 0)
+			if err != nil {
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
+				// 第一个报文发送失败(如目的不可达)，丢弃它继续发送后面的报文
+				gologger.Debugf("发送失败 %s: %s\n", batch[0].Addr, err.Error())
+				n = 1
+			}
+			batch = batch[n:]
+		}
+	}
+}
+
+// readLoop 批量读取响应，解码后写入响应通道
+func (t *udpTransport) readLoop(s *udpSocket) {
+	defer t.wg.Done()
+	ms := make([]ipv4.Message, udpBatchSize)
+	for i := range ms {
+		ms[i].Buffers = [][]byte{make([]byte, udpReadSize)}
+	}
+	var backoff time.Duration
+	for {
+		n, err := s.batch.ReadBatch(ms, 0)
+		if err != nil {
+			select {
+			case <-t.done:
+				return
+			default:
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			// 连续出错时指数退避，避免空转占满CPU
+			if backoff == 0 {
+				gologger.Warningf("端口 %d 读取响应失败: %v\n", s.port, err)
+				backoff = udpReadBackoffMin
+			} else if backoff *= 2; backoff > udpReadBackoffMax {
+				backoff = udpReadBackoffMax
+			}
+			select {
+			case <-t.done:
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		backoff = 0
+		for _, m := range ms[:n] {
+			addr, ok := m.Addr.(*net.UDPAddr)
+			if !ok {
+				continue
+			}
+			resp := dnsResponse{
+				port:     s.port,
+				resolver: options.JoinResolver(addr.IP, uint16(addr.Port)),
+			}
+			// 接收缓冲区会被复用，解码一份独立的副本
+			data := append([]byte(nil), m.Buffers[0][:m.N]...)
+			if err := resp.dns.DecodeFromBytes(data, gopacket.NilDecodeFeedback); err != nil {
+				continue
+			}
+			if !resp.dns.QR || len(resp.dns.Questions) == 0 {
+				continue
+			}
+			select {
+			case t.C <- resp:
+			case <-t.done:
+				return
+			}
+		}
+	}
+}
+
+func (t *udpTransport) Responses() <-chan dnsResponse {
+	return t.C
+}
+
+func (t *udpTransport) Done() <-chan struct{} {
+	return t.done
+}
+
+// Close 关闭所有 socket 并等待收发协程退出
+func (t *udpTransport) Close() {
+	t.once.Do(func() {
+		close(t.done)
+		t.closeSockets()
+		t.wg.Wait()
+	})
+}
+
+func (t *udpTransport) closeSockets() {
+	for _, socks := range []map[uint16]*udpSocket{t.v4, t.v6} {
+		for _, s := range socks {
+			s.conn.Close()
+		}
+	}
+}
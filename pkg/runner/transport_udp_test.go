@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/google/gopacket/layers"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/ipv4"
+)
+
+// startTestResolver 在本机启动一个解析器，a.example.com 解析为 1.2.3.4，其余返回 NXDOMAIN
+func startTestResolver(t *testing.T) string {
+	pc, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &dns.Server{PacketConn: pc, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, req *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(req)
+		if req.Question[0].Name == "a.example.com." && req.Question[0].Qtype == dns.TypeA {
+			m.Answer = append(m.Answer, &dns.A{
+				Hdr: dns.RR_Header{Name: req.Question[0].Name, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 60},
+				A:   net.ParseIP("1.2.3.4"),
+			})
+		} else {
+			m.Rcode = dns.RcodeNameError
+		}
+		_ = w.WriteMsg(m)
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return pc.LocalAddr().String()
+}
+
+func TestUDPTransport(t *testing.T) {
+	resolver := startTestResolver(t)
+	tr, err := newUDPTransport(2, 16)
+	assert.NoError(t, err)
+	defer tr.Close()
+	assert.Len(t, tr.Ports(), 2)
+
+	port := tr.Ports()[1]
+	tr.Send("a.example.com", resolver, 0x1234, port, layers.DNSTypeA, true)
+	select {
+	case resp := <-tr.Responses():
+		assert.Equal(t, resolver, resp.resolver)
+		assert.Equal(t, port, resp.port)
+		assert.Equal(t, uint16(0x1234), resp.dns.ID)
+		assert.Equal(t, "a.example.com", string(resp.dns.Questions[0].Name))
+		assert.Equal(t, "1.2.3.4", resp.dns.Answers[0].IP.String())
+	case <-time.After(3 * time.Second):
+		t.Fatal("没有收到响应")
+	}
+
+	tr.Close()
+	select {
+	case <-tr.Done():
+	default:
+		t.Fatal("关闭后 Done 未关闭")
+	}
+}
+
+// collectOutput 收集扫描结果
+type collectOutput struct {
+	mu      sync.Mutex
+	results []result.Result
+}
+
+func (c *collectOutput) WriteDomainResult(r result.Result) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.results = append(c.results, r)
+	return nil
+}
+
+func (c *collectOutput) Close() error { return nil }
+
+func TestRunnerUDPTransport(t *testing.T) {
+	resolver := startTestResolver(t)
+	domains := make(chan string)
+	go func() {
+		for _, d := range []string{"a.example.com", "b.example.com"} {
+			domains <- d
+		}
+		close(domains)
+	}()
+	out := &collectOutput{}
+	opt := &options.Options{
+		Rate:               1000,
+		Domain:             domains,
+		Resolvers:          []string{resolver},
+		Silent:             true,
+		TimeOut:            3,
+		Retry:              1,
+		Method:             options.VerifyType,
+		Writer:             []outputter.Output{out},
+		WildcardFilterMode: "none",
+		Transport:          options.TransportUDP,
+	}
+	r, err := New(opt)
+	if !assert.NoError(t, err) {
+		return
+	}
+	r.RunEnumeration(context.Background())
+	r.Close()
+
+	if assert.Len(t, out.results, 1) {
+		assert.Equal(t, "a.example.com", out.results[0].Subdomain)
+		assert.Equal(t, "1.2.3.4", out.results[0].Answers[0].Value)
+	}
+	assert.Equal(t, uint64(2), r.receiveCount)
+}
+
+// errBatchConn 每次读取都返回同一个非关闭错误
+type errBatchConn struct {
+	reads int32
+}
+
+func (c *errBatchConn) ReadBatch(ms []ipv4.Message, flags int) (int, error) {
+	atomic.AddInt32(&c.reads, 1)
+	return 0, errors.New("connection refused")
+}
+
+func (c *errBatchConn) WriteBatch(ms []ipv4.Message, flags int) (int, error) {
+	return len(ms), nil
+}
+
+func TestUDPReadLoopBackoff(t *testing.T) {
+	conn := &errBatchConn{}
+	tr := &udpTransport{C: make(chan dnsResponse), done: make(chan struct{})}
+	tr.wg.Add(1)
+	go tr.readLoop(&udpSocket{batch: conn, port: 1})
+	time.Sleep(200 * time.Millisecond)
+	close(tr.done)
+	tr.wg.Wait()
+	// 退避时 200ms 内只会重试少数几次，不会空转
+	reads := atomic.LoadInt32(&conn.reads)
+	assert.True(t, reads > 1 && reads < 20, "reads=%d", reads)
+}
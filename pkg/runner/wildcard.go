@@ -1,26 +1,351 @@
 package runner
 
 import (
+	"context"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/boy-hack/ksubdomain/v2/pkg/core"
-	"net"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/statusdb"
+	"github.com/google/gopacket/layers"
+	"golang.org/x/net/publicsuffix"
+)
+
+const (
+	wildcardProbeCount = 4    // 每个区域探测的随机子域名数量
+	wildcardProbeRate  = 1000 // 泛解析探测的发包速率上限
 )
 
-func IsWildCard(domain string) (bool, []string) {
-	var ret []string
-	for i := 0; i < 4; i++ {
-		subdomain := core.RandomStr(6) + "." + domain
-		ips, err := net.LookupIP(subdomain)
-		if err != nil {
+// WildcardZone 区域的泛解析探测结果，记录随机子域名返回的全部A/AAAA、CNAME记录和TTL
+type WildcardZone struct {
+	Zone     string
+	Wildcard bool
+	IPs      map[string]struct{}
+	CNAMEs   map[string]struct{}
+	TTLs     map[uint32]struct{}
+}
+
+func newWildcardZone(zone string) *WildcardZone {
+	return &WildcardZone{
+		Zone:   zone,
+		IPs:    make(map[string]struct{}),
+		CNAMEs: make(map[string]struct{}),
+		TTLs:   make(map[uint32]struct{}),
+	}
+}
+
+// add 记录一次随机子域名探测返回的记录
+func (z *WildcardZone) add(records []result.Record) {
+	for _, record := range records {
+		switch {
+		case record.IsIP():
+			z.IPs[record.Value] = struct{}{}
+		case record.Type == "CNAME":
+			z.CNAMEs[record.Value] = struct{}{}
+		default:
+			continue
+		}
+		z.Wildcard = true
+		z.TTLs[record.TTL] = struct{}{}
+	}
+}
+
+// Match 结果中的IP和CNAME记录是否全部来自该区域的泛解析
+func (z *WildcardZone) Match(res result.Result) bool {
+	if !z.Wildcard {
+		return false
+	}
+	matched := false
+	for _, answer := range res.Answers {
+		var ok bool
+		switch {
+		case answer.IsIP():
+			_, ok = z.IPs[answer.Value]
+		case answer.Type == "CNAME":
+			_, ok = z.CNAMEs[answer.Value]
+		default:
 			continue
 		}
-		for _, ip := range ips {
-			if ip.To4() != nil {
-				ret = append(ret, ip.String())
+		if !ok {
+			return false
+		}
+		matched = true
+	}
+	return matched
+}
+
+// wildcardZonesOf 返回域名所属的各级区域，由近及远，到可注册域名为止，不探测 co.uk 这类公共后缀
+// 如 a.dev.example.com 返回 dev.example.com、example.com，a.dev.example.co.uk 返回 dev.example.co.uk、example.co.uk
+func wildcardZonesOf(domain string) []string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(domain)
+	if err != nil {
+		return nil
+	}
+	var zones []string
+	for zone := domain; zone != registrable; {
+		i := strings.IndexByte(zone, '.')
+		if i < 0 {
+			break
+		}
+		zone = zone[i+1:]
+		zones = append(zones, zone)
+	}
+	return zones
+}
+
+// wildcardVerdict 泛解析判断结果
+type wildcardVerdict int
+
+const (
+	wildcardClean   wildcardVerdict = iota // 不是泛解析结果
+	wildcardMatched                        // 与所属区域的泛解析记录一致
+	wildcardPending                        // 所属区域仍在探测，结果暂存
+)
+
+// wildcardDetector 扫描过程中按发现的每一级区域探测泛解析，
+// 区域探测完成前，属于该区域的结果暂存等待判断
+type wildcardDetector struct {
+	mu       sync.Mutex
+	zones    map[string]*WildcardZone
+	probing  map[string]bool
+	held     []result.Result
+	inflight int // 已取出、正在重新判断的暂存结果数量
+	requests chan []string
+	ready    chan struct{}
+}
+
+func newWildcardDetector() *wildcardDetector {
+	return &wildcardDetector{
+		zones:    make(map[string]*WildcardZone),
+		probing:  make(map[string]bool),
+		requests: make(chan []string, 1024),
+		ready:    make(chan struct{}, 1),
+	}
+}
+
+// request 返回尚未探测完成的区域，并对其中未发起探测的区域发起探测
+func (d *wildcardDetector) request(ctx context.Context, zones []string) []string {
+	d.mu.Lock()
+	var unknown, request []string
+	for _, zone := range zones {
+		if _, ok := d.zones[zone]; ok {
+			continue
+		}
+		unknown = append(unknown, zone)
+		if !d.probing[zone] {
+			d.probing[zone] = true
+			request = append(request, zone)
+		}
+	}
+	d.mu.Unlock()
+	if len(request) > 0 {
+		select {
+		case d.requests <- request:
+		case <-ctx.Done():
+		}
+	}
+	return unknown
+}
+
+// check 判断结果是否为泛解析，所属区域未探测时发起探测并暂存结果
+func (d *wildcardDetector) check(ctx context.Context, res result.Result) wildcardVerdict {
+	zones := wildcardZonesOf(res.Subdomain)
+	if len(d.request(ctx, zones)) > 0 {
+		d.mu.Lock()
+		d.held = append(d.held, res)
+		d.mu.Unlock()
+		return wildcardPending
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, zone := range zones {
+		if d.zones[zone].Match(res) {
+			return wildcardMatched
+		}
+	}
+	return wildcardClean
+}
+
+// zone 返回区域的探测结果，尚未探测完成时发起探测并返回nil
+func (d *wildcardDetector) zone(ctx context.Context, name string) *WildcardZone {
+	if len(d.request(ctx, []string{name})) > 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.zones[name]
+}
+
+// finish 保存探测结果，并通知结果处理协程重新判断暂存的结果
+func (d *wildcardDetector) finish(zones map[string]*WildcardZone) {
+	d.mu.Lock()
+	for name, zone := range zones {
+		d.zones[name] = zone
+		delete(d.probing, name)
+	}
+	d.mu.Unlock()
+	select {
+	case d.ready <- struct{}{}:
+	default:
+	}
+}
+
+// takeHeld 取出全部暂存结果，处理完成后需调用 releaseHeld
+func (d *wildcardDetector) takeHeld() []result.Result {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	held := d.held
+	d.held = nil
+	d.inflight += len(held)
+	return held
+}
+
+// releaseHeld 标记取出的暂存结果已处理完成
+func (d *wildcardDetector) releaseHeld(n int) {
+	d.mu.Lock()
+	d.inflight -= n
+	d.mu.Unlock()
+}
+
+// heldItems 将暂存的结果转换为待查询项，用于保存断点
+func (d *wildcardDetector) heldItems() []statusdb.Item {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	items := make([]statusdb.Item, 0, len(d.held))
+	for _, res := range d.held {
+		qtype := layers.DNSTypeA
+		if types, err := options.ParseQueryTypes([]string{res.Type}); err == nil {
+			qtype = types[0]
+		}
+		items = append(items, statusdb.Item{Domain: res.Subdomain, QType: qtype})
+	}
+	return items
+}
+
+// Busy 是否还有正在探测的区域或暂存的结果
+func (d *wildcardDetector) Busy() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.probing) > 0 || len(d.held) > 0 || d.inflight > 0
+}
+
+// Zones 已探测的区域，按名称排序
+func (d *wildcardDetector) Zones() []*WildcardZone {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	zones := make([]*WildcardZone, 0, len(d.zones))
+	for _, zone := range d.zones {
+		zones = append(zones, zone)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Zone < zones[j].Zone })
+	return zones
+}
+
+// wildcardWorker 合并探测请求，通过原始发包引擎向每个区域发送随机子域名查询
+func (r *Runner) wildcardWorker(ctx context.Context) {
+	var prober *rawProber
+	defer func() {
+		if prober != nil {
+			// 等待权威服务器发送队列中的探测退出后再关闭传输
+			if r.authority != nil {
+				r.authority.wg.Wait()
 			}
+			prober.Close()
+		}
+	}()
+	for {
+		var batch []string
+		select {
+		case <-ctx.Done():
+			return
+		case batch = <-r.wildcard.requests:
 		}
+		// 合并排队中的请求
+	drain:
+		for {
+			select {
+			case more := <-r.wildcard.requests:
+				batch = append(batch, more...)
+			default:
+				break drain
+			}
+		}
+
+		if prober == nil {
+			var err error
+			rate := int64(math.Min(float64(r.options.Rate), wildcardProbeRate))
+			prober, err = newRawProber(r.options.Transport, r.options.EtherInfo, rate)
+			if err != nil {
+				// 无法探测时按非泛解析处理，避免结果一直暂存
+				gologger.Warningf("泛解析探测初始化失败: %v\n", err)
+				zones := make(map[string]*WildcardZone, len(batch))
+				for _, zone := range batch {
+					zones[zone] = newWildcardZone(zone)
+				}
+				r.wildcard.finish(zones)
+				continue
+			}
+			prober.authority = r.authority
+		}
+		r.wildcard.finish(r.probeWildcardZones(ctx, prober, batch))
 	}
-	if len(ret) == 0 {
-		return true, nil
+}
+
+// probeWildcardZones 对每个区域查询多个随机子域名，汇总返回的记录
+func (r *Runner) probeWildcardZones(ctx context.Context, prober *rawProber, zones []string) map[string]*WildcardZone {
+	qtypes := []layers.DNSType{layers.DNSTypeA}
+	for _, qtype := range r.queryTypes {
+		if qtype == layers.DNSTypeAAAA {
+			qtypes = append(qtypes, qtype)
+		}
+	}
+
+	var queries []probeKey
+	owner := make(map[string]string)
+	for _, zone := range zones {
+		for i := 0; i < wildcardProbeCount; i++ {
+			name := core.RandomStr(8) + "." + zone
+			owner[name] = zone
+			for _, qtype := range qtypes {
+				queries = append(queries, probeKey{resolver: r.selectDNSServer(name), name: name, qtype: qtype})
+			}
+		}
+	}
+	timeout := time.Duration(r.timeoutSeconds) * time.Second
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	replies := prober.Exchange(ctx, queries, timeout, r.maxRetryCount)
+
+	ret := make(map[string]*WildcardZone, len(zones))
+	for _, zone := range zones {
+		ret[zone] = newWildcardZone(zone)
+	}
+	for key, reply := range replies {
+		ret[owner[key.name]].add(reply.answers)
+	}
+	for _, zone := range ret {
+		if zone.Wildcard {
+			gologger.Warningf("检测到泛解析区域：%s (IP: %s CNAME: %s)\n", zone.Zone,
+				core.SliceToString(setKeys(zone.IPs)), core.SliceToString(setKeys(zone.CNAMEs)))
+		}
+	}
+	return ret
+}
+
+// setKeys 返回集合中排序后的元素
+func setKeys(set map[string]struct{}) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
 	}
-	return false, ret
+	sort.Strings(keys)
+	return keys
 }
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/boy-hack/ksubdomain/v2/pkg/core/options"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/outputter/output"
+	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWildcardZonesOf(t *testing.T) {
+	assert.Equal(t, []string{"dev.example.com", "example.com"}, wildcardZonesOf("a.dev.example.com"))
+	assert.Equal(t, []string{"example.com"}, wildcardZonesOf("www.example.com"))
+	assert.Empty(t, wildcardZonesOf("example.com"))
+	assert.Equal(t, []string{"dev.example.co.uk", "example.co.uk"}, wildcardZonesOf("a.dev.example.co.uk"))
+	assert.Empty(t, wildcardZonesOf("example.co.uk"))
+	assert.Empty(t, wildcardZonesOf("co.uk"))
+}
+
+func TestWildcardDetector(t *testing.T) {
+	ctx := context.Background()
+	d := newWildcardDetector()
+	res := result.Result{
+		Subdomain: "x.dev.example.com",
+		Answers:   []result.Record{{Type: "A", Value: "10.0.0.1"}},
+	}
+
+	// 区域未探测时暂存结果并发起探测
+	assert.Equal(t, wildcardPending, d.check(ctx, res))
+	assert.ElementsMatch(t, []string{"dev.example.com", "example.com"}, <-d.requests)
+	assert.True(t, d.Busy())
+
+	dev := newWildcardZone("dev.example.com")
+	dev.add([]result.Record{{Type: "A", Value: "10.0.0.1", TTL: 60}, {Type: "A", Value: "10.0.0.2", TTL: 60}})
+	d.finish(map[string]*WildcardZone{"dev.example.com": dev, "example.com": newWildcardZone("example.com")})
+	<-d.ready
+	held := d.takeHeld()
+	assert.Len(t, held, 1)
+	assert.True(t, d.Busy())
+	d.releaseHeld(len(held))
+	assert.False(t, d.Busy())
+
+	assert.Equal(t, wildcardMatched, d.check(ctx, res))
+	legit := result.Result{
+		Subdomain: "api.dev.example.com",
+		Answers:   []result.Record{{Type: "A", Value: "10.0.0.1"}, {Type: "A", Value: "192.168.1.1"}},
+	}
+	assert.Equal(t, wildcardClean, d.check(ctx, legit))
+	assert.Equal(t, wildcardClean, d.check(ctx, result.Result{
+		Subdomain: "www.example.com",
+		Answers:   []result.Record{{Type: "A", Value: "10.0.0.1"}},
+	}))
+}
+
+func TestWildcardOutputFilter(t *testing.T) {
+	wild := result.Result{Subdomain: "x.example.com", Answers: []result.Record{{Type: "A", Value: "10.0.0.1"}}}
+	clean := result.Result{Subdomain: "www.example.com", Answers: []result.Record{{Type: "A", Value: "192.168.1.1"}}}
+
+	// 区域判定为泛解析的结果不写入任何输出
+	buf, _ := output.NewBuffOutput()
+	r := &Runner{
+		options:    &options.Options{WildcardFilterMode: "zone-probe", Writer: []outputter.Output{buf}},
+		resultChan: make(chan result.Result, 2),
+		wildcard:   newWildcardDetector(),
+	}
+	zone := newWildcardZone("example.com")
+	zone.Wildcard = true
+	zone.add([]result.Record{{Type: "A", Value: "10.0.0.1", TTL: 60}})
+	r.wildcard.finish(map[string]*WildcardZone{"example.com": zone})
+	<-r.wildcard.ready
+
+	r.resultChan <- wild
+	r.resultChan <- clean
+	close(r.resultChan)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	r.handleResultWithContext(context.Background(), &wg)
+	assert.Equal(t, "www.example.com=>192.168.1.1\n", buf.Strings())
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"github.com/boy-hack/ksubdomain/v2/pkg/core/gologger"
 	"github.com/boy-hack/ksubdomain/v2/pkg/runner/result"
 	"sort"
@@ -29,69 +30,143 @@ func sortMapByValue(m map[string]int) PairList {
 	return p
 }
 
-// WildFilterOutputResult 泛解析过滤结果
-func WildFilterOutputResult(outputType string, results []result.Result) []result.Result {
-	if outputType == "none" {
-		return results
-	} else if outputType == "basic" {
-		return FilterWildCard(results)
-	} else if outputType == "advanced" {
-		return FilterWildCardAdvanced(results)
+// WildcardFilter 泛解析过滤器
+// 按区域探测的过滤在扫描过程中由 runner 实时完成；需要整体统计的过滤分两遍处理：
+// 第一遍对全部结果调用 Observe 收集统计，第二遍对每条结果调用 Filter，
+// 统计信息只与IP、CNAME目标相关，调用方无需在内存中保存全部结果
+type WildcardFilter interface {
+	// ZoneProbe 是否在扫描过程中对每一级区域探测泛解析并实时过滤
+	ZoneProbe() bool
+	// PostProcess 是否需要在扫描结束后基于全部结果统计过滤
+	PostProcess() bool
+	Observe(res result.Result)
+	Filter(res result.Result) (result.Result, bool)
+}
+
+// DefaultWildcardFilterMode 默认的泛解析过滤模式
+const DefaultWildcardFilterMode = "zone-probe"
+
+// wildcardFilters 泛解析过滤模式注册表，过滤器有状态，每次使用时新建
+var wildcardFilters = map[string]func() WildcardFilter{
+	"none":       func() WildcardFilter { return noneFilter{} },
+	"basic":      func() WildcardFilter { return newBasicFilter() },
+	"advanced":   func() WildcardFilter { return newAdvancedFilter() },
+	"zone-probe": func() WildcardFilter { return zoneProbeFilter{} },
+	"combined":   func() WildcardFilter { return combinedFilter{newAdvancedFilter()} },
+}
+
+// WildcardFilterModes 返回全部支持的过滤模式
+func WildcardFilterModes() []string {
+	modes := make([]string, 0, len(wildcardFilters))
+	for mode := range wildcardFilters {
+		modes = append(modes, mode)
 	}
-	return nil
+	sort.Strings(modes)
+	return modes
+}
+
+// NewWildcardFilter 根据过滤模式创建过滤器，模式为空时使用默认模式
+func NewWildcardFilter(mode string) (WildcardFilter, error) {
+	if mode == "" {
+		mode = DefaultWildcardFilterMode
+	}
+	factory, ok := wildcardFilters[mode]
+	if !ok {
+		return nil, fmt.Errorf("不支持的泛解析过滤模式: %s (支持: %s)", mode, strings.Join(WildcardFilterModes(), ", "))
+	}
+	return factory(), nil
 }
 
-// FilterWildCard 基于Result类型数据过滤泛解析
-// 传入参数为[]result.Result，返回过滤后的[]result.Result
-// 通过分析整体结果，对解析记录中相同的ip进行阈值判断，超过则丢弃该结果
-func FilterWildCard(results []result.Result) []result.Result {
-	if len(results) == 0 {
-		return results
+// CheckLiveWildcardMode 校验实时输出(屏幕、jsonl、SDK结果通道)可用的过滤模式：
+// 实时输出逐条写出结果，无法使用需要在扫描结束后统计全部结果的模式
+func CheckLiveWildcardMode(mode string) error {
+	filter, err := NewWildcardFilter(mode)
+	if err != nil {
+		return err
 	}
+	if filter.PostProcess() {
+		return fmt.Errorf("泛解析过滤模式 %s 需要在扫描结束后统计全部结果，只能用于 txt/json/csv 文件输出，实时输出请使用 none 或 zone-probe", mode)
+	}
+	return nil
+}
+
+// noneFilter 不过滤
+type noneFilter struct{}
+
+func (noneFilter) ZoneProbe() bool { return false }
+
+func (noneFilter) PostProcess() bool { return false }
+
+func (noneFilter) Observe(result.Result) {}
+
+func (noneFilter) Filter(res result.Result) (result.Result, bool) {
+	return res, true
+}
+
+// zoneProbeFilter 仅在扫描过程中按区域探测过滤，输出时不再处理
+type zoneProbeFilter struct {
+	noneFilter
+}
+
+func (zoneProbeFilter) ZoneProbe() bool { return true }
 
-	gologger.Debugf("泛解析处理中，共 %d 条记录...\n", len(results))
+// combinedFilter 扫描过程中按区域探测过滤，结束后再用高级统计过滤
+type combinedFilter struct {
+	*advancedFilter
+}
 
+func (combinedFilter) ZoneProbe() bool { return true }
+
+// basicFilter 基于IP出现频率的泛解析过滤
+type basicFilter struct {
 	// 统计每个IP出现的次数
-	ipFrequency := make(map[string]int)
-	// 记录IP到域名的映射关系
-	ipToDomains := make(map[string][]string)
+	ipFrequency map[string]int
 	// 域名计数
-	totalDomains := len(results)
-
-	// 第一遍扫描，统计IP频率
-	for _, res := range results {
-		for _, answer := range res.Answers {
-			// 跳过非IP的记录(CNAME等)
-			if !strings.HasPrefix(answer, "CNAME ") && !strings.HasPrefix(answer, "NS ") &&
-				!strings.HasPrefix(answer, "TXT ") && !strings.HasPrefix(answer, "PTR ") {
-				ipFrequency[answer]++
-				ipToDomains[answer] = append(ipToDomains[answer], res.Subdomain)
-			}
+	totalDomains int
+	// 疑似泛解析的IP列表，第一次过滤时计算
+	suspiciousIPs map[string]bool
+}
+
+func newBasicFilter() *basicFilter {
+	return &basicFilter{ipFrequency: make(map[string]int)}
+}
+
+func (f *basicFilter) ZoneProbe() bool { return false }
+
+func (f *basicFilter) PostProcess() bool { return true }
+
+// Observe 第一遍扫描，统计IP频率
+func (f *basicFilter) Observe(res result.Result) {
+	f.totalDomains++
+	for _, answer := range res.Answers {
+		// 跳过非IP的记录(CNAME等)
+		if answer.IsIP() {
+			f.ipFrequency[answer.Value]++
 		}
 	}
+}
 
-	// 按出现频率排序IP
-	sortedIPs := sortMapByValue(ipFrequency)
-
-	// 确定疑似泛解析的IP列表
-	// 使用两个标准：
-	// 1. IP解析超过总域名数量的特定百分比(动态阈值)
-	// 2. 该IP解析的子域名数量超过特定阈值
-	suspiciousIPs := make(map[string]bool)
+// prepare 确定疑似泛解析的IP列表
+// 使用两个标准：
+// 1. IP解析超过总域名数量的特定百分比(动态阈值)
+// 2. 该IP解析的子域名数量超过特定阈值
+func (f *basicFilter) prepare() {
+	f.suspiciousIPs = make(map[string]bool)
 
-	for _, pair := range sortedIPs {
+	// 按出现频率排序IP
+	for _, pair := range sortMapByValue(f.ipFrequency) {
 		ip := pair.Key
 		count := pair.Value
 
 		// 计算该IP解析占总体的百分比
-		percentage := float64(count) / float64(totalDomains) * 100
+		percentage := float64(count) / float64(f.totalDomains) * 100
 
 		// 动态阈值：根据总域名数量调整
 		// 域名数量少时阈值较高，域名数量多时阈值较低
 		var threshold float64
-		if totalDomains < 100 {
+		if f.totalDomains < 100 {
 			threshold = 30 // 如果域名总数小于100，阈值设为30%
-		} else if totalDomains < 1000 {
+		} else if f.totalDomains < 1000 {
 			threshold = 20 // 如果域名总数在100-1000，阈值设为20%
 		} else {
 			threshold = 10 // 如果域名总数超过1000，阈值设为10%
@@ -104,128 +179,125 @@ func FilterWildCard(results []result.Result) []result.Result {
 		if percentage > threshold || count > absoluteThreshold {
 			gologger.Debugf("发现可疑泛解析IP: %s (解析了 %d 个域名, %.2f%%)\n",
 				ip, count, percentage)
-			suspiciousIPs[ip] = true
+			f.suspiciousIPs[ip] = true
 		}
 	}
+}
 
-	// 第二遍扫描，过滤结果
-	var filteredResults []result.Result
-
-	for _, res := range results {
-		// 检查该域名的所有IP是否均为可疑IP
-		// 如果有不可疑的IP，保留该记录
-		validRecord := false
-		var filteredAnswers []string
+// Filter 第二遍扫描，过滤结果
+func (f *basicFilter) Filter(res result.Result) (result.Result, bool) {
+	if f.suspiciousIPs == nil {
+		f.prepare()
+	}
 
-		for _, answer := range res.Answers {
-			// 保留所有非IP记录(如CNAME)
-			if strings.HasPrefix(answer, "CNAME ") || strings.HasPrefix(answer, "NS ") ||
-				strings.HasPrefix(answer, "TXT ") || strings.HasPrefix(answer, "PTR ") {
-				validRecord = true
-				filteredAnswers = append(filteredAnswers, answer)
-			} else if !suspiciousIPs[answer] {
-				// 保留不在可疑IP列表中的IP
-				validRecord = true
-				filteredAnswers = append(filteredAnswers, answer)
-			}
+	// 检查该域名的所有IP是否均为可疑IP
+	// 如果有不可疑的IP，保留该记录
+	validRecord := false
+	var filteredAnswers []result.Record
+
+	for _, answer := range res.Answers {
+		// 保留所有非IP记录(如CNAME)
+		if !answer.IsIP() {
+			validRecord = true
+			filteredAnswers = append(filteredAnswers, answer)
+		} else if !f.suspiciousIPs[answer.Value] {
+			// 保留不在可疑IP列表中的IP
+			validRecord = true
+			filteredAnswers = append(filteredAnswers, answer)
 		}
+	}
 
-		if validRecord && len(filteredAnswers) > 0 {
-			filteredRes := result.Result{
-				Subdomain: res.Subdomain,
-				Answers:   filteredAnswers,
-			}
-			filteredResults = append(filteredResults, filteredRes)
-		}
+	if validRecord && len(filteredAnswers) > 0 {
+		filteredRes := res
+		filteredRes.Answers = filteredAnswers
+		return filteredRes, true
 	}
+	return result.Result{}, false
+}
+
+// advancedFilter 结合IP频率、前缀多样性、TLD多样性和CNAME聚类的泛解析过滤
+type advancedFilter struct {
+	// 统计IP出现频率
+	ipFrequency map[string]int
+	// 统计每个IP解析的不同子域名前缀数量
+	ipPrefixVariety map[string]map[string]bool
+	// 统计IP解析的不同顶级域数量
+	ipTLDVariety map[string]map[string]bool
+	// 统计CNAME目标的指向次数
+	cnameTargetCount map[string]int
 
-	gologger.Infof("泛解析过滤完成，从 %d 条记录中过滤出 %d 条有效记录\n",
-		totalDomains, len(filteredResults))
+	totalDomains int
 
-	return filteredResults
+	prepared         bool
+	suspiciousIPs    map[string]float64 // IP -> 可疑度分数(0-100)
+	suspiciousCnames map[string]bool
 }
 
-// FilterWildCardAdvanced 提供更高级的泛解析检测算法
-// 使用多种启发式方法和特征检测来识别泛解析
-func FilterWildCardAdvanced(results []result.Result) []result.Result {
-	if len(results) == 0 {
-		return results
+func newAdvancedFilter() *advancedFilter {
+	return &advancedFilter{
+		ipFrequency:      make(map[string]int),
+		ipPrefixVariety:  make(map[string]map[string]bool),
+		ipTLDVariety:     make(map[string]map[string]bool),
+		cnameTargetCount: make(map[string]int),
 	}
+}
 
-	gologger.Debugf("高级泛解析检测开始，共 %d 条记录...\n", len(results))
+func (f *advancedFilter) ZoneProbe() bool { return false }
+
+func (f *advancedFilter) PostProcess() bool { return true }
+
+// Observe 第一轮：收集统计信息
+func (f *advancedFilter) Observe(res result.Result) {
+	f.totalDomains++
+	subdomain := res.Subdomain
+	parts := strings.Split(subdomain, ".")
+
+	// 提取顶级域和前缀
+	prefix := ""
+	tld := ""
+	if len(parts) > 1 {
+		prefix = parts[0]
+		tld = strings.Join(parts[1:], ".")
+	} else {
+		prefix = subdomain
+		tld = subdomain
+	}
 
-	// 统计IP出现频率
-	ipFrequency := make(map[string]int)
-	// 统计每个IP解析的不同子域名前缀数量
-	ipPrefixVariety := make(map[string]map[string]bool)
-	// 统计IP解析的不同顶级域数量
-	ipTLDVariety := make(map[string]map[string]bool)
-	// 记录IP到域名的映射
-	ipToDomains := make(map[string][]string)
-	// 记录CNAME信息
-	cnameRecords := make(map[string][]string)
-
-	totalDomains := len(results)
-
-	// 第一轮：收集统计信息
-	for _, res := range results {
-		subdomain := res.Subdomain
-		parts := strings.Split(subdomain, ".")
-
-		// 提取顶级域和前缀
-		prefix := ""
-		tld := ""
-		if len(parts) > 1 {
-			prefix = parts[0]
-			tld = strings.Join(parts[1:], ".")
-		} else {
-			prefix = subdomain
-			tld = subdomain
+	for _, record := range res.Answers {
+		if record.Type == "CNAME" {
+			// 统计CNAME目标
+			f.cnameTargetCount[record.Value]++
+			continue
 		}
 
-		for _, answer := range res.Answers {
-			if strings.HasPrefix(answer, "CNAME ") {
-				// 提取CNAME目标
-				cnameParts := strings.SplitN(answer, " ", 2)
-				if len(cnameParts) == 2 {
-					cnameTarget := cnameParts[1]
-					cnameRecords[subdomain] = append(cnameRecords[subdomain], cnameTarget)
-				}
-				continue
-			}
+		// 只处理IP记录
+		if record.IsIP() {
+			answer := record.Value
+			// 计数IP频率
+			f.ipFrequency[answer]++
 
-			// 只处理IP记录
-			if !strings.HasPrefix(answer, "NS ") &&
-				!strings.HasPrefix(answer, "TXT ") &&
-				!strings.HasPrefix(answer, "PTR ") {
-				// 计数IP频率
-				ipFrequency[answer]++
-
-				// 初始化IP的前缀集合和TLD集合
-				if ipPrefixVariety[answer] == nil {
-					ipPrefixVariety[answer] = make(map[string]bool)
-				}
-				if ipTLDVariety[answer] == nil {
-					ipTLDVariety[answer] = make(map[string]bool)
-				}
-
-				// 记录这个IP解析了哪些不同的前缀和TLD
-				ipPrefixVariety[answer][prefix] = true
-				ipTLDVariety[answer][tld] = true
-
-				// 记录IP到域名的映射
-				ipToDomains[answer] = append(ipToDomains[answer], subdomain)
+			// 初始化IP的前缀集合和TLD集合
+			if f.ipPrefixVariety[answer] == nil {
+				f.ipPrefixVariety[answer] = make(map[string]bool)
 			}
+			if f.ipTLDVariety[answer] == nil {
+				f.ipTLDVariety[answer] = make(map[string]bool)
+			}
+
+			// 记录这个IP解析了哪些不同的前缀和TLD
+			f.ipPrefixVariety[answer][prefix] = true
+			f.ipTLDVariety[answer][tld] = true
 		}
 	}
+}
 
-	// 按照IP频率排序
-	sortedIPs := sortMapByValue(ipFrequency)
-
-	// 识别可疑IP列表
-	suspiciousIPs := make(map[string]float64) // IP -> 可疑度分数(0-100)
+// prepare 识别可疑IP与可疑CNAME目标
+func (f *advancedFilter) prepare() {
+	f.prepared = true
+	f.suspiciousIPs = make(map[string]float64)
 
-	for _, pair := range sortedIPs {
+	// 按照IP频率排序
+	for _, pair := range sortMapByValue(f.ipFrequency) {
 		ip := pair.Key
 		count := pair.Value
 
@@ -233,14 +305,14 @@ func FilterWildCardAdvanced(results []result.Result) []result.Result {
 		suspiciousScore := 0.0
 
 		// 因子1: IP频率百分比
-		freqPercentage := float64(count) / float64(totalDomains) * 100
+		freqPercentage := float64(count) / float64(f.totalDomains) * 100
 
 		// 因子2: 前缀多样性
-		prefixVariety := len(ipPrefixVariety[ip])
+		prefixVariety := len(f.ipPrefixVariety[ip])
 		prefixVarietyRatio := float64(prefixVariety) / float64(count) * 100
 
 		// 因子3: TLD多样性
-		tldVariety := len(ipTLDVariety[ip])
+		tldVariety := len(f.ipTLDVariety[ip])
 
 		// 计算可疑度分数
 		// 1. 频率因子
@@ -280,87 +352,66 @@ func FilterWildCardAdvanced(results []result.Result) []result.Result {
 		if suspiciousScore >= 35 {
 			gologger.Debugf("可疑IP: %s (解析域名数: %d, 占比: %.2f%%, 前缀多样性: %d/%d, 可疑度: %.2f)\n",
 				ip, count, freqPercentage, prefixVariety, count, suspiciousScore)
-			suspiciousIPs[ip] = suspiciousScore
+			f.suspiciousIPs[ip] = suspiciousScore
 		}
 	}
 
-	// 第二轮：过滤结果
-	var filteredResults []result.Result
-
-	// CNAME聚类分析：检测指向相同目标的多个CNAME记录
-	cnameTargetCount := make(map[string]int)
-	for _, targets := range cnameRecords {
-		for _, target := range targets {
-			cnameTargetCount[target]++
+	// CNAME聚类分析：识别被大量域名指向的可疑CNAME目标
+	f.suspiciousCnames = make(map[string]bool)
+	for cname, count := range f.cnameTargetCount {
+		if count > 5 && float64(count)/float64(f.totalDomains)*100 > 10 {
+			gologger.Debugf("可疑CNAME目标: %s (指向次数: %d)\n", cname, count)
+			f.suspiciousCnames[cname] = true
 		}
 	}
+}
 
-	// 识别可疑CNAME目标
-	suspiciousCnames := make(map[string]bool)
-	for cname, count := range cnameTargetCount {
-		if count > 5 && float64(count)/float64(totalDomains)*100 > 10 {
-			gologger.Debugf("可疑CNAME目标: %s (指向次数: %d)\n", cname, count)
-			suspiciousCnames[cname] = true
+// Filter 第二轮：过滤结果
+func (f *advancedFilter) Filter(res result.Result) (result.Result, bool) {
+	if !f.prepared {
+		f.prepare()
+	}
+
+	// 检查是否含有可疑CNAME
+	hasSuspiciousCname := false
+	for _, answer := range res.Answers {
+		if answer.Type == "CNAME" && f.suspiciousCnames[answer.Value] {
+			hasSuspiciousCname = true
+			break
 		}
 	}
 
-	// 过滤结果
-	for _, res := range results {
-		// 检查是否含有可疑CNAME
-		hasSuspiciousCname := false
-		if targets, ok := cnameRecords[res.Subdomain]; ok {
-			for _, target := range targets {
-				if suspiciousCnames[target] {
-					hasSuspiciousCname = true
-					break
-				}
+	validRecord := !hasSuspiciousCname
+	var filteredAnswers []result.Record
+
+	// 处理所有回答
+	for _, answer := range res.Answers {
+		isIP := answer.IsIP()
+
+		// 保留所有非IP记录但排除可疑CNAME
+		if !isIP {
+			if answer.Type == "CNAME" && f.suspiciousCnames[answer.Value] {
+				continue // 跳过可疑CNAME
 			}
-		}
+			validRecord = true
+			filteredAnswers = append(filteredAnswers, answer)
+		} else {
+			// 针对IP记录，根据可疑度评分过滤
+			suspiciousScore, isSuspicious := f.suspiciousIPs[answer.Value]
 
-		validRecord := !hasSuspiciousCname
-		var filteredAnswers []string
-
-		// 处理所有回答
-		for _, answer := range res.Answers {
-			isIP := !strings.HasPrefix(answer, "CNAME ") &&
-				!strings.HasPrefix(answer, "NS ") &&
-				!strings.HasPrefix(answer, "TXT ") &&
-				!strings.HasPrefix(answer, "PTR ")
-
-			// 保留所有非IP记录但排除可疑CNAME
-			if !isIP {
-				if strings.HasPrefix(answer, "CNAME ") {
-					cnameParts := strings.SplitN(answer, " ", 2)
-					if len(cnameParts) == 2 && suspiciousCnames[cnameParts[1]] {
-						continue // 跳过可疑CNAME
-					}
-				}
+			// 如果不在可疑IP列表中，或者可疑度较低，则保留
+			if !isSuspicious || suspiciousScore < 50 {
 				validRecord = true
 				filteredAnswers = append(filteredAnswers, answer)
-			} else {
-				// 针对IP记录，根据可疑度评分过滤
-				suspiciousScore, isSuspicious := suspiciousIPs[answer]
-
-				// 如果不在可疑IP列表中，或者可疑度较低，则保留
-				if !isSuspicious || suspiciousScore < 50 {
-					validRecord = true
-					filteredAnswers = append(filteredAnswers, answer)
-				}
 			}
 		}
-
-		// 只添加有效记录
-		if validRecord && len(filteredAnswers) > 0 {
-			filteredRes := result.Result{
-				Subdomain: res.Subdomain,
-				Answers:   filteredAnswers,
-			}
-			filteredResults = append(filteredResults, filteredRes)
-		}
 	}
 
-	gologger.Infof("高级泛解析过滤完成，从 %d 条记录中过滤出 %d 条有效记录\n",
-		totalDomains, len(filteredResults))
-
-	return filteredResults
+	// 只添加有效记录
+	if validRecord && len(filteredAnswers) > 0 {
+		filteredRes := res
+		filteredRes.Answers = filteredAnswers
+		return filteredRes, true
+	}
+	return result.Result{}, false
 }
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewWildcardFilter(t *testing.T) {
+	assert.Equal(t, []string{"advanced", "basic", "combined", "none", "zone-probe"}, WildcardFilterModes())
+
+	filter, err := NewWildcardFilter("")
+	assert.NoError(t, err)
+	assert.True(t, filter.ZoneProbe())
+	assert.False(t, filter.PostProcess())
+
+	filter, err = NewWildcardFilter("combined")
+	assert.NoError(t, err)
+	assert.True(t, filter.ZoneProbe())
+	assert.True(t, filter.PostProcess())
+
+	filter, err = NewWildcardFilter("basic")
+	assert.NoError(t, err)
+	assert.False(t, filter.ZoneProbe())
+	assert.True(t, filter.PostProcess())
+
+	_, err = NewWildcardFilter("local")
+	assert.Error(t, err)
+}
+
+func TestCheckLiveWildcardMode(t *testing.T) {
+	for _, mode := range []string{"", "none", "zone-probe"} {
+		assert.NoError(t, CheckLiveWildcardMode(mode), mode)
+	}
+	for _, mode := range []string{"basic", "advanced", "combined", "local"} {
+		assert.Error(t, CheckLiveWildcardMode(mode), mode)
+	}
+}